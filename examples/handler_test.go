@@ -3,6 +3,7 @@ package Generator
 import (
 	"context"
 	"fmt"
+	"github.com/CUHK-SE-Group/generic-generator/handlers"
 	"github.com/CUHK-SE-Group/generic-generator/parser"
 	"github.com/CUHK-SE-Group/generic-generator/schemas"
 	"testing"
@@ -20,7 +21,7 @@ func TestDefaultHandlerCypher(t *testing.T) {
 	g.BuildShortestNotation()
 	consg := schemas.NewConstraintGraph()
 	consg.AddBinaryConstraint(cons)
-	chain, err := schemas.CreateChain("test", &MonitorHandler{}, &schemas.OptionHandler{}, &MonitorHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.SubHandler{}, &WeightedHandler{}, &schemas.TermHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{})
+	chain, err := schemas.CreateChain("test", &handlers.MonitorHandler{}, &schemas.OptionHandler{}, &handlers.MonitorHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.SubHandler{}, &handlers.WeightedHandler{}, &schemas.TermHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{})
 	if err != nil {
 		panic(err)
 	}
@@ -44,7 +45,7 @@ func TestLLVMIRHandler(t *testing.T) {
 	}
 	g.MergeProduction()
 	g.BuildShortestNotation()
-	chain, err := schemas.CreateChain("test", &MonitorHandler{}, &schemas.PlusHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.TermHandler{}, &WeightedHandler{}, &schemas.OrHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{})
+	chain, err := schemas.CreateChain("test", &handlers.MonitorHandler{}, &schemas.PlusHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.TermHandler{}, &handlers.WeightedHandler{}, &schemas.OrHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{})
 	if err != nil {
 		panic(err)
 	}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/CUHK-SE-Group/generic-generator/graph"
+	"github.com/CUHK-SE-Group/generic-generator/handlers"
 	"github.com/CUHK-SE-Group/generic-generator/parser"
 	"github.com/CUHK-SE-Group/generic-generator/schemas"
 	"log"
@@ -40,7 +41,6 @@ func (h *dummyHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb sch
 	chain.Next(ctx, cb)
 
 	if ctx.Error != nil {
-		panic(ctx.Error)
 		return
 	}
 	ctx.SymbolStack.Pop()
@@ -62,7 +62,7 @@ func TestDefaultHandler(t *testing.T) {
 	}
 	g.MergeProduction()
 	g.BuildShortestNotation()
-	chain, err := schemas.CreateChain("test", &dummyHandler{}, &schemas.TraceHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.SubHandler{}, &WeightedHandler{}, &schemas.TermHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{})
+	chain, err := schemas.CreateChain("test", &dummyHandler{}, &schemas.TraceHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.SubHandler{}, &handlers.WeightedHandler{}, &schemas.TermHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{})
 	if err != nil {
 		panic(err)
 	}
@@ -102,7 +102,7 @@ func TestWeightedHandler(t *testing.T) {
 	consg.AddBinaryConstraint(cons)
 	g.MergeProduction()
 	g.BuildShortestNotation()
-	chain, err := schemas.CreateChain("test", &MonitorHandler{}, &dummyHandler{}, &schemas.IDHandler{}, &schemas.CatHandler{}, &WeightedHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{}, &schemas.TermHandler{})
+	chain, err := schemas.CreateChain("test", &handlers.MonitorHandler{}, &dummyHandler{}, &schemas.IDHandler{}, &schemas.CatHandler{}, &handlers.WeightedHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{}, &schemas.TermHandler{})
 	if err != nil {
 		panic(err)
 	}
@@ -144,12 +144,12 @@ func TestHandlerChainMux(t *testing.T) {
 	g.MergeProduction()
 	g.BuildShortestNotation()
 
-	routerHandler := &WrapHandler{Chain: map[schemas.GrammarType]*schemas.Chain{}}
-	err = routerHandler.Register(wrapChain(&schemas.IDHandler{}), wrapChain(&schemas.CatHandler{}), wrapChain(&WeightedHandler{}), wrapChain(&schemas.RepHandler{}), wrapChain(&schemas.BracketHandler{}), wrapChain(&schemas.TermHandler{}))
+	routerHandler := &handlers.MuxHandler{}
+	err = routerHandler.Register(wrapChain(&schemas.IDHandler{}), wrapChain(&schemas.CatHandler{}), wrapChain(&handlers.WeightedHandler{}), wrapChain(&schemas.RepHandler{}), wrapChain(&schemas.BracketHandler{}), wrapChain(&schemas.TermHandler{}))
 	if err != nil {
 		panic(err)
 	}
-	chain, err := schemas.CreateChain("main", &MonitorHandler{}, routerHandler)
+	chain, err := schemas.CreateChain("main", &handlers.MonitorHandler{}, routerHandler)
 	if err != nil {
 		panic(err)
 	}
@@ -271,7 +271,7 @@ func TestWeightHandlerManyTimes(t *testing.T) {
 	}
 	g.MergeProduction()
 	g.BuildShortestNotation()
-	chain, err := schemas.CreateChain("test", &MonitorHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.TermHandler{}, &WeightedHandler{}, &schemas.OrHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{})
+	chain, err := schemas.CreateChain("test", &handlers.MonitorHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.TermHandler{}, &handlers.WeightedHandler{}, &schemas.OrHandler{}, &schemas.RepHandler{}, &schemas.BracketHandler{})
 	if err != nil {
 		panic(err)
 	}
@@ -307,3 +307,32 @@ func TestWeightHandlerManyTimes(t *testing.T) {
 		t.Fatalf("could not write memory profile: %v", err)
 	}
 }
+
+func TestLoadChain(t *testing.T) {
+	g, err := parser.Parse("./testdata/complete/tinyc.ebnf", "program")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.MergeProduction()
+	g.BuildShortestNotation()
+	chain, err := schemas.LoadChain("./testdata/chains/mux.yaml", g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := schemas.NewContext(g, "program", context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Budget = schemas.Budget{MaxTokens: 200}
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Result.GetResult(nil) == "" {
+		t.Error("nothing was generated")
+	}
+}
+
+func wrapChain(h schemas.Handler) *schemas.Chain {
+	chain, _ := schemas.CreateChain(h.Name(), h)
+	return chain
+}
@@ -3,7 +3,6 @@ module github.com/CUHK-SE-Group/generic-generator
 go 1.21
 
 require (
-	github.com/hashicorp/go-immutable-radix v1.3.0 // indirect
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
 	golang.org/x/exp v0.0.0-20230515195305-f3d0a9c9a5cc // indirect
 )
@@ -11,7 +10,9 @@ require (
 require (
 	github.com/IBM/fp-go v1.0.56
 	github.com/antlr4-go/antlr/v4 v4.13.0
+	github.com/hashicorp/go-immutable-radix v1.3.0
 	github.com/hashicorp/go-memdb v1.3.4
 	github.com/lucasjones/reggen v0.0.0-20200904144131-37ba4fa293bb
+	go.yaml.in/yaml/v3 v3.0.4
 	google.golang.org/protobuf v1.32.0
 )
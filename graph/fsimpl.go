@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"log/slog"
 	"os"
+	"sync"
 )
 
 type FSGraph[EdgePropertyType any, VertexPropertyType any] struct {
@@ -16,6 +17,8 @@ type FSGraph[EdgePropertyType any, VertexPropertyType any] struct {
 	Vertex2OutEdges map[string][]Edge[EdgePropertyType, VertexPropertyType]
 	Vertex2InEdges  map[string][]Edge[EdgePropertyType, VertexPropertyType]
 	Dirty           bool
+
+	mu sync.RWMutex // guards the maps, so that concurrent readers may rebuild the index
 }
 
 type FSVertexImpl[PropertyType any] struct {
@@ -68,38 +71,68 @@ func (g *FSGraph[EdgePropertyType, VertexPropertyType]) updateIndex() {
 	}
 }
 
+// rlockIndex read-locks the graph with an up-to-date index.
+func (g *FSGraph[EdgePropertyType, VertexPropertyType]) rlockIndex() {
+	g.mu.RLock()
+	for g.Dirty {
+		g.mu.RUnlock()
+		g.mu.Lock()
+		g.updateIndex()
+		g.mu.Unlock()
+		g.mu.RLock()
+	}
+}
+
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) SetMetadata(key Metadata, val any) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	g.Metadata[key] = val
 }
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) GetMetadata(key Metadata) any {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.Metadata[key]
 }
 
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) GetAllMetadata() map[Metadata]any {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.Metadata
 }
 
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) GetVertexById(id string) Vertex[VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.VertexMap[id]
 }
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) GetEdgeById(id string) Edge[EdgePropertyType, VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.EdgeMap[id]
 }
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) AddEdge(edge Edge[EdgePropertyType, VertexPropertyType]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	if _, ok := g.EdgeMap[edge.GetID()]; ok {
 		slog.Debug("edge already exists", "Id", edge.GetID(), "From", edge.GetFrom().GetID(), "To", edge.GetTo().GetID())
 	}
 	g.EdgeMap[edge.GetID()] = edge
 	if _, ok := g.VertexMap[edge.GetFrom().GetID()]; !ok {
-		g.AddVertex(edge.GetFrom())
+		g.addVertex(edge.GetFrom())
 	}
 	if _, ok := g.VertexMap[edge.GetTo().GetID()]; !ok {
-		g.AddVertex(edge.GetTo())
+		g.addVertex(edge.GetTo())
 	}
 	g.Dirty = true
 }
 
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) AddVertex(vertex Vertex[VertexPropertyType]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.addVertex(vertex)
+}
+
+func (g *FSGraph[EdgePropertyType, VertexPropertyType]) addVertex(vertex Vertex[VertexPropertyType]) {
 	if _, ok := g.VertexMap[vertex.GetID()]; ok {
 		slog.Warn("vertex already exists", "Id", vertex.GetID())
 	}
@@ -108,33 +141,40 @@ func (g *FSGraph[EdgePropertyType, VertexPropertyType]) AddVertex(vertex Vertex[
 }
 
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) DeleteEdge(edge Edge[EdgePropertyType, VertexPropertyType]) {
-	if _, ok := g.EdgeMap[edge.GetID()]; ok {
-		delete(g.EdgeMap, edge.GetID())
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.EdgeMap[edge.GetID()]; !ok {
+		slog.Warn("edge does not exist", "Id", edge.GetID())
 	}
-	slog.Warn("edge does not exist", "Id", edge.GetID())
+	delete(g.EdgeMap, edge.GetID())
 	g.Dirty = true
 }
 
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) DeleteVertex(vertex Vertex[VertexPropertyType]) {
-	if _, ok := g.VertexMap[vertex.GetID()]; ok {
-		delete(g.VertexMap, vertex.GetID())
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.VertexMap[vertex.GetID()]; !ok {
+		slog.Warn("vertex does not exist", "Id", vertex.GetID())
 	}
-	slog.Warn("vertex does not exist", "Id", vertex.GetID())
+	delete(g.VertexMap, vertex.GetID())
 	g.Dirty = true
 }
 
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) GetOutEdges(vertex Vertex[VertexPropertyType]) []Edge[EdgePropertyType, VertexPropertyType] {
-	g.updateIndex()
+	g.rlockIndex()
+	defer g.mu.RUnlock()
 	return g.Vertex2OutEdges[vertex.GetID()]
 }
 
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) GetInEdges(vertex Vertex[VertexPropertyType]) []Edge[EdgePropertyType, VertexPropertyType] {
-	g.updateIndex()
+	g.rlockIndex()
+	defer g.mu.RUnlock()
 	return g.Vertex2InEdges[vertex.GetID()]
 }
 
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) GetAllVertices() []Vertex[VertexPropertyType] {
-	g.updateIndex()
+	g.rlockIndex()
+	defer g.mu.RUnlock()
 	var all []Vertex[VertexPropertyType]
 	for _, v := range g.VertexMap {
 		all = append(all, v)
@@ -143,7 +183,8 @@ func (g *FSGraph[EdgePropertyType, VertexPropertyType]) GetAllVertices() []Verte
 }
 
 func (g *FSGraph[EdgePropertyType, VertexPropertyType]) GetAllEdges() []Edge[EdgePropertyType, VertexPropertyType] {
-	g.updateIndex()
+	g.rlockIndex()
+	defer g.mu.RUnlock()
 	var all []Edge[EdgePropertyType, VertexPropertyType]
 	for _, e := range g.EdgeMap {
 		all = append(all, e)
@@ -218,6 +218,7 @@ func GenerateCypher[EdgePropertyType any, VertexPropertyType any](graph Graph[Ed
 
 type Options struct {
 	FSEnabled    bool
+	Shared       bool // see SharedGraph
 	ReadFileName string
 }
 type Option func(*Options)
@@ -228,12 +229,25 @@ func WithPersistent(persistent bool) Option {
 	}
 }
 
+// WithShared makes a SharedGraph, whose forks share its structure.
+func WithShared(shared bool) Option {
+	return func(o *Options) {
+		o.Shared = shared
+	}
+}
+
 func NewGraph[EdgePropertyType any, VertexPropertyType any](opts ...Option) Graph[EdgePropertyType, VertexPropertyType] {
 	options := Options{}
 	for _, opt := range opts {
 		opt(&options)
 	}
 
+	if options.Shared {
+		m := newSharedGraph[EdgePropertyType, VertexPropertyType]()
+		m.SetMetadata(CleanVertexByEdge, false)
+		return m
+	}
+
 	if options.FSEnabled {
 		m := &FSGraph[EdgePropertyType, VertexPropertyType]{
 			EdgeMap:         make(map[string]Edge[EdgePropertyType, VertexPropertyType]),
@@ -2,6 +2,7 @@ package graph
 
 import (
 	"log/slog"
+	"sync"
 )
 
 const (
@@ -18,6 +19,8 @@ type MemGraph[EdgePropertyType any, VertexPropertyType any] struct {
 	vertex2OutEdges map[string][]Edge[EdgePropertyType, VertexPropertyType]
 	vertex2InEdges  map[string][]Edge[EdgePropertyType, VertexPropertyType]
 	dirty           bool
+
+	mu sync.RWMutex // guards the maps, so that concurrent readers may rebuild the index
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) updateIndex() {
@@ -56,38 +59,68 @@ func (g *MemGraph[EdgePropertyType, VertexPropertyType]) updateIndex() {
 	}
 }
 
+// rlockIndex read-locks the graph with an up-to-date index.
+func (g *MemGraph[EdgePropertyType, VertexPropertyType]) rlockIndex() {
+	g.mu.RLock()
+	for g.dirty {
+		g.mu.RUnlock()
+		g.mu.Lock()
+		g.updateIndex()
+		g.mu.Unlock()
+		g.mu.RLock()
+	}
+}
+
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) SetMetadata(key Metadata, val any) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	g.metadata[key] = val
 }
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) GetMetadata(key Metadata) any {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.metadata[key]
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) GetAllMetadata() map[Metadata]any {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.metadata
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) GetVertexById(id string) Vertex[VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.vertexMap[id]
 }
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) GetEdgeById(id string) Edge[EdgePropertyType, VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.edgeMap[id]
 }
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) AddEdge(edge Edge[EdgePropertyType, VertexPropertyType]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	if _, ok := g.edgeMap[edge.GetID()]; ok {
 		slog.Debug("edge already exists", "Id", edge.GetID(), "From", edge.GetFrom().GetID(), "To", edge.GetTo().GetID())
 	}
 	g.edgeMap[edge.GetID()] = edge
 	if _, ok := g.vertexMap[edge.GetFrom().GetID()]; !ok {
-		g.AddVertex(edge.GetFrom())
+		g.addVertex(edge.GetFrom())
 	}
 	if _, ok := g.vertexMap[edge.GetTo().GetID()]; !ok {
-		g.AddVertex(edge.GetTo())
+		g.addVertex(edge.GetTo())
 	}
 	g.dirty = true
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) AddVertex(vertex Vertex[VertexPropertyType]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.addVertex(vertex)
+}
+
+func (g *MemGraph[EdgePropertyType, VertexPropertyType]) addVertex(vertex Vertex[VertexPropertyType]) {
 	if _, ok := g.vertexMap[vertex.GetID()]; ok {
 		slog.Warn("vertex already exists", "Id", vertex.GetID())
 	}
@@ -96,33 +129,40 @@ func (g *MemGraph[EdgePropertyType, VertexPropertyType]) AddVertex(vertex Vertex
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) DeleteEdge(edge Edge[EdgePropertyType, VertexPropertyType]) {
-	if _, ok := g.edgeMap[edge.GetID()]; ok {
-		delete(g.edgeMap, edge.GetID())
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.edgeMap[edge.GetID()]; !ok {
+		slog.Warn("edge does not exist", "Id", edge.GetID())
 	}
-	slog.Warn("edge does not exist", "Id", edge.GetID())
+	delete(g.edgeMap, edge.GetID())
 	g.dirty = true
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) DeleteVertex(vertex Vertex[VertexPropertyType]) {
-	if _, ok := g.vertexMap[vertex.GetID()]; ok {
-		delete(g.vertexMap, vertex.GetID())
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.vertexMap[vertex.GetID()]; !ok {
+		slog.Warn("vertex does not exist", "Id", vertex.GetID())
 	}
-	slog.Warn("vertex does not exist", "Id", vertex.GetID())
+	delete(g.vertexMap, vertex.GetID())
 	g.dirty = true
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) GetOutEdges(vertex Vertex[VertexPropertyType]) []Edge[EdgePropertyType, VertexPropertyType] {
-	g.updateIndex()
+	g.rlockIndex()
+	defer g.mu.RUnlock()
 	return g.vertex2OutEdges[vertex.GetID()]
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) GetInEdges(vertex Vertex[VertexPropertyType]) []Edge[EdgePropertyType, VertexPropertyType] {
-	g.updateIndex()
+	g.rlockIndex()
+	defer g.mu.RUnlock()
 	return g.vertex2InEdges[vertex.GetID()]
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) GetAllVertices() []Vertex[VertexPropertyType] {
-	g.updateIndex()
+	g.rlockIndex()
+	defer g.mu.RUnlock()
 	var all []Vertex[VertexPropertyType]
 	for _, v := range g.vertexMap {
 		all = append(all, v)
@@ -131,7 +171,8 @@ func (g *MemGraph[EdgePropertyType, VertexPropertyType]) GetAllVertices() []Vert
 }
 
 func (g *MemGraph[EdgePropertyType, VertexPropertyType]) GetAllEdges() []Edge[EdgePropertyType, VertexPropertyType] {
-	g.updateIndex()
+	g.rlockIndex()
+	defer g.mu.RUnlock()
 	var all []Edge[EdgePropertyType, VertexPropertyType]
 	for _, e := range g.edgeMap {
 		all = append(all, e)
@@ -0,0 +1,203 @@
+package graph
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+// SharedGraph is a graph whose forks share its structure, see Fork. The vertices, the edges
+// and the edge list of every vertex are kept in immutable radix trees, so that a write only
+// copies the path to what it touches, and the edge lists it changes. The vertices and the
+// edges may be shared by several graphs: they must not be modified once added.
+type SharedGraph[EdgePropertyType any, VertexPropertyType any] struct {
+	edges    *iradix.Tree // edge by ID
+	vertices *iradix.Tree // vertex by ID
+	out      *iradix.Tree // edges leaving a vertex, by vertex ID
+	in       *iradix.Tree // edges entering a vertex, by vertex ID
+	metadata map[Metadata]any
+
+	mu sync.RWMutex // guards the roots, so that readers may run along a writer
+}
+
+func newSharedGraph[EdgePropertyType any, VertexPropertyType any]() *SharedGraph[EdgePropertyType, VertexPropertyType] {
+	return &SharedGraph[EdgePropertyType, VertexPropertyType]{
+		edges:    iradix.New(),
+		vertices: iradix.New(),
+		out:      iradix.New(),
+		in:       iradix.New(),
+		metadata: make(map[Metadata]any),
+	}
+}
+
+// Fork returns a copy of the graph in constant time, the graph and the copy may then be written independently.
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) Fork() Graph[EdgePropertyType, VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return &SharedGraph[EdgePropertyType, VertexPropertyType]{
+		edges:    g.edges,
+		vertices: g.vertices,
+		out:      g.out,
+		in:       g.in,
+		metadata: maps.Clone(g.metadata),
+	}
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) SetMetadata(key Metadata, val any) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.metadata[key] = val
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) GetMetadata(key Metadata) any {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.metadata[key]
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) GetAllMetadata() map[Metadata]any {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.metadata
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) GetVertexById(id string) Vertex[VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if v, ok := g.vertices.Get([]byte(id)); ok {
+		return v.(Vertex[VertexPropertyType])
+	}
+	return nil
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) GetEdgeById(id string) Edge[EdgePropertyType, VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if e, ok := g.edges.Get([]byte(id)); ok {
+		return e.(Edge[EdgePropertyType, VertexPropertyType])
+	}
+	return nil
+}
+
+// list returns the edge list of the vertex id in the index.
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) list(index *iradix.Tree, id string) []Edge[EdgePropertyType, VertexPropertyType] {
+	if l, ok := index.Get([]byte(id)); ok {
+		return l.([]Edge[EdgePropertyType, VertexPropertyType])
+	}
+	return nil
+}
+
+// link adds the edge to the edge list of the vertex id in the index, the list is copied.
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) link(index *iradix.Tree, id string, edge Edge[EdgePropertyType, VertexPropertyType]) *iradix.Tree {
+	l := g.list(index, id)
+	res, _, _ := index.Insert([]byte(id), append(slices.Clip(l), edge))
+	return res
+}
+
+// unlink removes the edge from the edge list of the vertex id in the index, the list is copied.
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) unlink(index *iradix.Tree, id string, edge Edge[EdgePropertyType, VertexPropertyType]) *iradix.Tree {
+	l := slices.DeleteFunc(slices.Clone(g.list(index, id)), func(e Edge[EdgePropertyType, VertexPropertyType]) bool {
+		return e.GetID() == edge.GetID()
+	})
+	if len(l) == 0 {
+		res, _, _ := index.Delete([]byte(id))
+		return res
+	}
+	res, _, _ := index.Insert([]byte(id), l)
+	return res
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) AddEdge(edge Edge[EdgePropertyType, VertexPropertyType]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if old, ok := g.edges.Get([]byte(edge.GetID())); ok {
+		slog.Debug("edge already exists", "Id", edge.GetID(), "From", edge.GetFrom().GetID(), "To", edge.GetTo().GetID())
+		g.deleteEdge(old.(Edge[EdgePropertyType, VertexPropertyType]))
+	}
+	g.edges, _, _ = g.edges.Insert([]byte(edge.GetID()), edge)
+	g.out = g.link(g.out, edge.GetFrom().GetID(), edge)
+	g.in = g.link(g.in, edge.GetTo().GetID(), edge)
+	if _, ok := g.vertices.Get([]byte(edge.GetFrom().GetID())); !ok {
+		g.addVertex(edge.GetFrom())
+	}
+	if _, ok := g.vertices.Get([]byte(edge.GetTo().GetID())); !ok {
+		g.addVertex(edge.GetTo())
+	}
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) AddVertex(vertex Vertex[VertexPropertyType]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.addVertex(vertex)
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) addVertex(vertex Vertex[VertexPropertyType]) {
+	var old any
+	var ok bool
+	if g.vertices, old, ok = g.vertices.Insert([]byte(vertex.GetID()), vertex); ok && old != nil {
+		slog.Warn("vertex already exists", "Id", vertex.GetID())
+	}
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) DeleteEdge(edge Edge[EdgePropertyType, VertexPropertyType]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	old, ok := g.edges.Get([]byte(edge.GetID()))
+	if !ok {
+		slog.Warn("edge does not exist", "Id", edge.GetID())
+		return
+	}
+	g.deleteEdge(old.(Edge[EdgePropertyType, VertexPropertyType]))
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) deleteEdge(edge Edge[EdgePropertyType, VertexPropertyType]) {
+	g.edges, _, _ = g.edges.Delete([]byte(edge.GetID()))
+	g.out = g.unlink(g.out, edge.GetFrom().GetID(), edge)
+	g.in = g.unlink(g.in, edge.GetTo().GetID(), edge)
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) DeleteVertex(vertex Vertex[VertexPropertyType]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var ok bool
+	if g.vertices, _, ok = g.vertices.Delete([]byte(vertex.GetID())); !ok {
+		slog.Warn("vertex does not exist", "Id", vertex.GetID())
+	}
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) GetOutEdges(vertex Vertex[VertexPropertyType]) []Edge[EdgePropertyType, VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.list(g.out, vertex.GetID())
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) GetInEdges(vertex Vertex[VertexPropertyType]) []Edge[EdgePropertyType, VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.list(g.in, vertex.GetID())
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) GetAllVertices() []Vertex[VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var all []Vertex[VertexPropertyType]
+	g.vertices.Root().Walk(func(_ []byte, v interface{}) bool {
+		all = append(all, v.(Vertex[VertexPropertyType]))
+		return false
+	})
+	return all
+}
+
+func (g *SharedGraph[EdgePropertyType, VertexPropertyType]) GetAllEdges() []Edge[EdgePropertyType, VertexPropertyType] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var all []Edge[EdgePropertyType, VertexPropertyType]
+	g.edges.Root().Walk(func(_ []byte, e interface{}) bool {
+		all = append(all, e.(Edge[EdgePropertyType, VertexPropertyType]))
+		return false
+	})
+	return all
+}
@@ -0,0 +1,182 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/handlers"
+	"github.com/CUHK-SE-Group/generic-generator/parser"
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// firstHandler always chooses the first alternative of the OR nodes of term.
+type firstHandler struct{}
+
+func (h *firstHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	ctx.ResultBuffer = append(ctx.ResultBuffer, ctx.CurrentNode.GetSymbols()[len(ctx.CurrentNode.GetSymbols())-1])
+	chain.Next(ctx, cb)
+}
+
+func (h *firstHandler) HookRoute() []regexp.Regexp {
+	return []regexp.Regexp{*regexp.MustCompile("^term#")}
+}
+
+func (h *firstHandler) Name() string {
+	return "first"
+}
+
+func (h *firstHandler) Type() schemas.GrammarType {
+	return schemas.GrammarOR
+}
+
+// leafHandler leaves the terminals as they are.
+type leafHandler struct{}
+
+func (h *leafHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	chain.Next(ctx, cb)
+}
+
+func (h *leafHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *leafHandler) Name() string {
+	return "leaf"
+}
+
+func (h *leafHandler) Type() schemas.GrammarType {
+	return schemas.GrammarTerminal
+}
+
+func chainOf(t *testing.T, name string, hs ...schemas.Handler) *schemas.Chain {
+	chain, err := schemas.CreateChain(name, hs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+func newMux(t *testing.T) *handlers.MuxHandler {
+	mux := &handlers.MuxHandler{}
+	err := mux.Register(
+		chainOf(t, "cat", &schemas.CatHandler{}),
+		chainOf(t, "id", &schemas.IDHandler{}),
+		chainOf(t, "leaf", &leafHandler{}),
+		chainOf(t, "weight", &handlers.WeightedHandler{PreferUnvisited: true}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mux
+}
+
+func generate(t *testing.T, chain *schemas.Chain, cons *schemas.ConstraintGraph) (*schemas.Context, error) {
+	g, err := parser.Parse("./testdata/expr.ebnf", "expr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.MergeProduction()
+	g.BuildShortestNotation()
+	ctx, err := schemas.NewContext(g, "expr", context.Background(), cons, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Budget = schemas.Budget{MaxTokens: 20}
+	_, err = schemas.Generate(ctx, chain)
+	return ctx, err
+}
+
+func TestMuxPriority(t *testing.T) {
+	mux := newMux(t)
+	if err := mux.RouteWithPriority(schemas.GrammarOR, chainOf(t, "first", &firstHandler{}), 1); err != nil {
+		t.Fatal(err)
+	}
+	chain := chainOf(t, "main", mux)
+	if err := schemas.ValidateChain(chain, mustParse(t)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		ctx, err := generate(t, chain, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range ctx.SymbolStack.GetTrace() {
+			if n.GetContent() == "'b'" {
+				t.Fatal("the terms should be chosen by the chain of higher priority")
+			}
+		}
+	}
+}
+
+func TestMuxUnhandled(t *testing.T) {
+	mux := &handlers.MuxHandler{}
+	if err := mux.Register(chainOf(t, "cat", &schemas.CatHandler{}), chainOf(t, "id", &schemas.IDHandler{})); err != nil {
+		t.Fatal(err)
+	}
+	chain := chainOf(t, "main", mux)
+	if err := schemas.ValidateChain(chain, mustParse(t)); !errors.Is(err, schemas.ErrUnhandledType) {
+		t.Errorf("got error %v, want the OR nodes to be unhandled", err)
+	}
+	if _, err := generate(t, chain, nil); !errors.Is(err, schemas.ErrUnhandledType) {
+		t.Errorf("got error %v, want the OR nodes to be unhandled", err)
+	}
+}
+
+func TestMuxNotAccepted(t *testing.T) {
+	// the only chain of the OR nodes is routed to the terms, no chain accepts the OR node of expr
+	mux := &handlers.MuxHandler{}
+	err := mux.Register(
+		chainOf(t, "cat", &schemas.CatHandler{}),
+		chainOf(t, "id", &schemas.IDHandler{}),
+		chainOf(t, "leaf", &leafHandler{}),
+		chainOf(t, "first", &firstHandler{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generate(t, chainOf(t, "main", mux), nil); !errors.Is(err, schemas.ErrDeadEnd) {
+		t.Errorf("got error %v, want the OR node of expr to be a dead end", err)
+	}
+}
+
+func TestMonitorHandler(t *testing.T) {
+	fail := errors.New("fail")
+	cons := schemas.NewConstraintGraph()
+	cons.AddBinaryConstraint(schemas.Constraint{
+		FirstNode:  "term",
+		SecondNode: "term",
+		FirstOp: schemas.Action{Type: schemas.FUNC, Func: func(ctx *schemas.Context) (*schemas.Context, error) {
+			return ctx, fail
+		}},
+	})
+	monitor := &handlers.MonitorHandler{}
+	if _, err := generate(t, chainOf(t, "main", monitor, newMux(t)), cons); !errors.Is(err, schemas.ErrConstraintUnsatisfiable) || !errors.Is(err, fail) {
+		t.Errorf("got error %v, want the constraint to fail", err)
+	}
+	monitor.IgnoreFailures = true
+	if _, err := generate(t, chainOf(t, "main", monitor, newMux(t)), cons); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadChain(t *testing.T) {
+	g := mustParse(t)
+	chain, err := schemas.LoadChain("./testdata/chain.yaml", g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generate(t, chain, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustParse(t *testing.T) *schemas.Grammar {
+	g, err := parser.Parse("./testdata/expr.ebnf", "expr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.MergeProduction()
+	return g
+}
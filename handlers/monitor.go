@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+	"github.com/CUHK-SE-Group/generic-generator/schemas/query"
+)
+
+// MonitorHandler runs the constraints of the Context whose nodes match the production
+// path of the current node. A failing constraint stops the generation with
+// schemas.ErrConstraintUnsatisfiable, unless IgnoreFailures is set.
+type MonitorHandler struct {
+	IgnoreFailures bool
+}
+
+func (h *MonitorHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	if ctx.Constraint == nil {
+		chain.Next(ctx, cb)
+		return
+	}
+	// the trace may share its array with checkpoints and forks of the Context
+	trace := append(slices.Clone(ctx.SymbolStack.ProductionTrace), strings.Split(ctx.SymbolStack.Top().GetID(), "#")[0])
+	ok := true
+	for _, v := range ctx.Constraint.GetConstraints() {
+		if query.MatchPattern(trace, v.FirstNode) {
+			if ctx, ok = h.run(ctx, v.FirstOp); !ok {
+				return
+			}
+		}
+		if query.MatchPattern(trace, v.SecondNode) {
+			if ctx, ok = h.run(ctx, v.SecondOp); !ok {
+				return
+			}
+		}
+	}
+	chain.Next(ctx, cb)
+}
+
+// run applies the operation, it reports false if the generation must stop.
+func (h *MonitorHandler) run(ctx *schemas.Context, op schemas.Action) (*schemas.Context, bool) {
+	if op.Type != schemas.FUNC {
+		return ctx, true
+	}
+	ctx, err := op.Func(ctx)
+	if err == nil || errors.Is(err, schemas.ErrPassThrough) || errors.Is(err, schemas.ErrIntercept) || h.IgnoreFailures {
+		return ctx, true
+	}
+	ctx.Error = fmt.Errorf("%w: %w", schemas.ErrConstraintUnsatisfiable, err)
+	return ctx, false
+}
+
+func (h *MonitorHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *MonitorHandler) Name() string {
+	return MonitorHandlerName
+}
+
+func (h *MonitorHandler) Type() schemas.GrammarType {
+	return math.MaxInt
+}
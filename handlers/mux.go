@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// MuxHandler drives the generation by routing every node to a chain of its grammar type,
+// and expands the node with what the chain put in the ResultBuffer. A type can have
+// several chains: they are tried by decreasing priority, then in the order they were
+// routed, and the first one that accepts the node (see schemas.Chain.Accepts) handles it.
+// If none accepts it, the generation stops with schemas.ErrDeadEnd.
+type MuxHandler struct {
+	routes []*muxRoute
+}
+
+type muxRoute struct {
+	types    schemas.GrammarType
+	priority int
+	chain    *schemas.Chain
+}
+
+func (h *MuxHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	// save and restore the environment
+	handlerIndex := ctx.HandlerIndex
+	ctx.HandlerIndex = 0
+	defer func() {
+		ctx.HandlerIndex = handlerIndex
+	}()
+
+	ctx.CurrentNode = ctx.SymbolStack.Top() // clear the message exchange buffer
+	ctx.ResultBuffer = make([]*schemas.Node, 0)
+	c, routed := h.route(ctx)
+	if !routed {
+		ctx.Error = fmt.Errorf("%w: %s", schemas.ErrUnhandledType, schemas.GetGrammarTypeStr(ctx.CurrentNode.GetType()))
+		return
+	}
+	if c == nil {
+		ctx.Error = fmt.Errorf("%w: no chain of the type %s accepts %s", schemas.ErrDeadEnd, schemas.GetGrammarTypeStr(ctx.CurrentNode.GetType()), ctx.CurrentNode.GetID())
+		return
+	}
+	c.Next(ctx, cb)
+	if ctx.Error != nil {
+		return
+	}
+	if len(ctx.ResultBuffer) == 0 && ctx.CurrentNode.GetType() == schemas.GrammarTerminal {
+		ctx.Tmp1 = append(ctx.Tmp1, strings.Trim(ctx.CurrentNode.GetContent(), "'"))
+		ctx.Result.AddEdge(ctx.CurrentNode, ctx.CurrentNode)
+	}
+	ctx.SymbolStack.Pop()
+	ctx.SymbolStack.Push(ctx.ResultBuffer...)
+	for i := len(ctx.ResultBuffer) - 1; i >= 0; i-- {
+		ctx.Result.AddNode(ctx.ResultBuffer[i])
+		ctx.Result.AddEdge(ctx.CurrentNode, ctx.ResultBuffer[i])
+	}
+}
+
+// route returns the chain which handles the current node, if any, and reports whether its type has a chain at all.
+func (h *MuxHandler) route(ctx *schemas.Context) (*schemas.Chain, bool) {
+	routed := false
+	for _, r := range h.routes {
+		if r.types&ctx.CurrentNode.GetType() == 0 {
+			continue
+		}
+		if r.chain.Accepts(ctx) {
+			return r.chain, true
+		}
+		routed = true
+	}
+	return nil, routed
+}
+
+// Route sends the nodes of the types t to chain, with the priority 0.
+func (h *MuxHandler) Route(t schemas.GrammarType, chain *schemas.Chain) error {
+	return h.RouteWithPriority(t, chain, 0)
+}
+
+// RouteWithPriority sends the nodes of the types t to chain, before the chains of a lower priority.
+func (h *MuxHandler) RouteWithPriority(t schemas.GrammarType, chain *schemas.Chain, priority int) error {
+	if len(chain.Handlers) == 0 {
+		return fmt.Errorf("the length of chain should not be zero")
+	}
+	h.routes = append(h.routes, &muxRoute{types: t, priority: priority, chain: chain})
+	sort.SliceStable(h.routes, func(i, j int) bool {
+		return h.routes[i].priority > h.routes[j].priority
+	})
+	return nil
+}
+
+// Register routes every chain by the type of its first handler.
+func (h *MuxHandler) Register(chain ...*schemas.Chain) error {
+	for _, v := range chain {
+		if len(v.Handlers) == 0 {
+			return fmt.Errorf("the length of chain should not be zero")
+		}
+		if err := h.Route(v.Handlers[0].Type(), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Routes is the union of the types with a chain.
+func (h *MuxHandler) Routes() schemas.GrammarType {
+	var res schemas.GrammarType
+	for _, r := range h.routes {
+		res |= r.types
+	}
+	return res
+}
+
+func (h *MuxHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *MuxHandler) Name() string {
+	return MuxHandlerName
+}
+
+func (h *MuxHandler) Type() schemas.GrammarType {
+	return math.MaxInt
+}
@@ -0,0 +1,92 @@
+// Package handlers holds handlers of general use: the router MuxHandler, the
+// shrink-aware WeightedHandler and the constraint-running MonitorHandler.
+// Importing it registers them in schemas.DefaultRegistry, see schemas.LoadChain.
+package handlers
+
+import (
+	"regexp"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+const (
+	WeightedHandlerName = "weight"
+	MonitorHandlerName  = "monitor"
+	MuxHandlerName      = "mux"
+)
+
+func init() {
+	schemas.Register(&WeightedHandler{})
+	schemas.Register(&MonitorHandler{})
+	schemas.Register(&MuxHandler{})
+}
+
+// WeightedHandler chooses an alternative of an OR node. While shrinking it prefers
+// the alternatives closer to a terminal, otherwise the ones fitting the budget.
+type WeightedHandler struct {
+	PreferUnvisited bool // weight the alternatives by 1/(1+visits) of their edge, to cover the grammar faster
+}
+
+func (h *WeightedHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	sym := ctx.CurrentNode.GetSymbols()
+	if len(sym) == 0 {
+		chain.Next(ctx, cb)
+		return
+	}
+
+	candidates := make([]*schemas.Node, 0)
+	repechage := make([]*schemas.Node, 0)
+	for _, v := range sym {
+		var ok bool
+		if ctx.Shrinking() {
+			ok = v.GetDistance() < ctx.CurrentNode.GetDistance()
+		} else {
+			ok = ctx.Fits(v)
+		}
+		if ok {
+			candidates = append(candidates, v)
+		} else {
+			repechage = append(repechage, v)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = repechage
+	}
+	chosen := h.choose(ctx, candidates)
+	ctx.VisitedEdge[schemas.GetEdgeID(ctx.CurrentNode.GetID(), chosen.GetID())]++
+	ctx.ResultBuffer = append(ctx.ResultBuffer, chosen)
+
+	chain.Next(ctx, cb)
+}
+
+func (h *WeightedHandler) choose(ctx *schemas.Context, candidates []*schemas.Node) *schemas.Node {
+	if !h.PreferUnvisited {
+		return candidates[ctx.Rand.Intn(len(candidates))]
+	}
+	weights := make([]float64, len(candidates))
+	total := 0.0
+	for i, v := range candidates {
+		weights[i] = 1 / float64(1+ctx.VisitedEdge[schemas.GetEdgeID(ctx.CurrentNode.GetID(), v.GetID())])
+		total += weights[i]
+	}
+	r := ctx.Rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return candidates[i]
+		}
+		r -= w
+	}
+	return candidates[len(candidates)-1]
+}
+
+func (h *WeightedHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *WeightedHandler) Name() string {
+	return WeightedHandlerName
+}
+
+func (h *WeightedHandler) Type() schemas.GrammarType {
+	return schemas.GrammarOR
+}
@@ -0,0 +1,100 @@
+package mutation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// Corpus keeps derivations, and indexes their subtrees by the production they derive, see Replace and Splice.
+type Corpus struct {
+	trees    []*schemas.DerivationTree
+	subtrees map[string][]*schemas.TreeNode
+}
+
+func NewCorpus() *Corpus {
+	return &Corpus{subtrees: make(map[string][]*schemas.TreeNode)}
+}
+
+// Add adds the derivation, which must be complete, and indexes its productions. Its text
+// is the one generated, the text of its regex terminals being recorded in the derivation.
+func (c *Corpus) Add(d *schemas.Derivation) error {
+	t := d.Tree()
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	c.trees = append(c.trees, t)
+	c.index(t)
+	return nil
+}
+
+func (c *Corpus) index(t *schemas.DerivationTree) {
+	t.Walk(func(n *schemas.TreeNode) bool {
+		if n.Type == schemas.GrammarProduction {
+			c.subtrees[n.Symbol.GetID()] = append(c.subtrees[n.Symbol.GetID()], n)
+		}
+		return true
+	})
+}
+
+// Dedup removes the derivations similar to a former one of the corpus, e.g. with
+// schemas.TreeEditDistance or schemas.KPathSimilarity, and returns how many were removed.
+func (c *Corpus) Dedup(similar func(a, b *schemas.DerivationTree) bool) int {
+	kept := make([]*schemas.DerivationTree, 0, len(c.trees))
+	for _, t := range c.trees {
+		if !slices.ContainsFunc(kept, func(k *schemas.DerivationTree) bool { return similar(k, t) }) {
+			kept = append(kept, t)
+		}
+	}
+	removed := len(c.trees) - len(kept)
+	c.trees = kept
+	c.subtrees = make(map[string][]*schemas.TreeNode)
+	for _, t := range kept {
+		c.index(t)
+	}
+	return removed
+}
+
+// Len returns the number of derivations of the corpus.
+func (c *Corpus) Len() int {
+	return len(c.trees)
+}
+
+// Subtrees returns the subtrees of the production in the corpus, they must not be modified.
+func (c *Corpus) Subtrees(production string) []*schemas.TreeNode {
+	return c.subtrees[production]
+}
+
+// Save writes the derivation trees of the corpus to filename in JSON, see schemas.UnmarshalTree.
+func (c *Corpus) Save(filename string) error {
+	data, err := json.Marshal(c.trees)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// Load adds the derivations written by Save, they are derivations of the grammar g.
+func (c *Corpus) Load(g *schemas.Grammar, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	trees := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(data, &trees); err != nil {
+		return fmt.Errorf("loading the corpus %s: %w", filename, err)
+	}
+	for i, tree := range trees {
+		d, err := schemas.UnmarshalTree(g, tree)
+		if err != nil {
+			return fmt.Errorf("loading the derivation %d of %s: %w", i, filename, err)
+		}
+		if err := c.Add(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+// Package mutation mutates the derivation trees of a grammar: every mutation of a valid
+// derivation is a valid derivation, so the inputs found near an interesting one stay
+// grammatical. A Mutator applies the operators, see Regenerate, Replace, Duplicate,
+// Remove, Swap, Recurse and Splice, chosen by a Scheduler from the outcomes of the former
+// mutations, and crosses derivations over, see Mutator.Crossover.
+package mutation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+var (
+	ErrNoCandidate     = errors.New("no node of the derivation can be mutated")
+	ErrUnknownOperator = errors.New("unknown mutation operator")
+)
+
+type Options struct {
+	Operators    []string
+	Corpus       *Corpus
+	Scheduler    *Scheduler
+	Seed         int64
+	MaxRecursion int
+	Context      context.Context
+	Setup        func(*schemas.Context)
+}
+
+type Option func(*Options)
+
+// WithOperators sets the operators Mutate chooses from, all of them by default.
+func WithOperators(operators ...string) Option {
+	return func(o *Options) {
+		o.Operators = operators
+	}
+}
+
+// WithCorpus sets the derivations Replace and Splice take subtrees from.
+func WithCorpus(corpus *Corpus) Option {
+	return func(o *Options) {
+		o.Corpus = corpus
+	}
+}
+
+// WithScheduler chooses the operators, and the productions they mutate, with the Scheduler instead of uniformly.
+func WithScheduler(s *Scheduler) Option {
+	return func(o *Options) {
+		o.Scheduler = s
+	}
+}
+
+// WithSeed seeds the random choices of the Mutator, the same seed makes the same mutations.
+func WithSeed(seed int64) Option {
+	return func(o *Options) {
+		o.Seed = seed
+	}
+}
+
+// WithMaxRecursion sets the largest number of times Recurse repeats a recursion, 3 by default.
+func WithMaxRecursion(n int) Option {
+	return func(o *Options) {
+		o.MaxRecursion = n
+	}
+}
+
+// WithContext sets the context of the generations of new subtrees.
+func WithContext(ctx context.Context) Option {
+	return func(o *Options) {
+		o.Context = ctx
+	}
+}
+
+// WithSetup is called on the Context of every generation of a new subtree, e.g. to set a Budget.
+func WithSetup(setup func(*schemas.Context)) Option {
+	return func(o *Options) {
+		o.Setup = setup
+	}
+}
+
+// Mutation is a derivation made by a Mutator.
+type Mutation struct {
+	Derivation *schemas.Derivation
+	Operator   string
+	Node       string // ID of the grammar node mutated
+	Production string // production the mutated node belongs to
+}
+
+// Mutator mutates derivations. The new subtrees are generated with its chain, driven like a
+// single generation: its first handler pops the symbol stack. A Mutator is not safe for concurrent use.
+type Mutator struct {
+	chain   *schemas.Chain
+	options Options
+	rand    *rand.Rand
+	op      string // operator being applied
+}
+
+func NewMutator(chain *schemas.Chain, opts ...Option) *Mutator {
+	options := Options{
+		Operators:    slices.Clone(Operators),
+		Seed:         rand.Int63(),
+		MaxRecursion: 3,
+		Context:      context.Background(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.MaxRecursion <= 0 {
+		options.MaxRecursion = 1
+	}
+	return &Mutator{
+		chain:   chain,
+		options: options,
+		rand:    rand.New(rand.NewSource(options.Seed)),
+	}
+}
+
+// Mutate applies one of the operators of the Mutator that apply to the derivation, chosen
+// at random or by the Scheduler. Report the outcome of the mutation to the Scheduler.
+func (m *Mutator) Mutate(d *schemas.Derivation) (*Mutation, error) {
+	operators := slices.Clone(m.options.Operators)
+	if m.options.Scheduler != nil {
+		operators = m.options.Scheduler.order(operators)
+	} else {
+		m.rand.Shuffle(len(operators), func(i, j int) {
+			operators[i], operators[j] = operators[j], operators[i]
+		})
+	}
+	for _, op := range operators {
+		res, err := m.Apply(d, op)
+		if errors.Is(err, ErrNoCandidate) {
+			continue
+		}
+		return res, err
+	}
+	return nil, ErrNoCandidate
+}
+
+// Apply applies the operator op to a copy of the derivation d, which must be complete.
+// The subtrees it keeps keep their text, e.g. the one recorded for a regex terminal.
+// It fails with ErrNoCandidate if no node of d can be mutated by op.
+func (m *Mutator) Apply(d *schemas.Derivation, op string) (*Mutation, error) {
+	operator, ok := operators[op]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownOperator, op)
+	}
+	t := d.Tree()
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	m.op = op
+	node, err := operator(m, t)
+	if err != nil {
+		return nil, err
+	}
+	return newMutation(op, t, node)
+}
+
+// Crossover swaps a subtree of the derivation a with a subtree of b derived from the same
+// production, with another text, and returns both offspring. They are grammatical, as the
+// subtrees swapped derive the same production. It fails with ErrNoCandidate if a and b
+// have no such subtrees.
+func (m *Mutator) Crossover(a, b *schemas.Derivation) (*Mutation, *Mutation, error) {
+	ta, tb := a.Tree(), b.Tree()
+	for _, t := range []*schemas.DerivationTree{ta, tb} {
+		if err := t.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	m.op = Splice
+	c, err := pick(m, crossings(ta, tb), func(c crossing) string {
+		return production(c.a)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	subA, subB := c.b.Clone(), c.a.Clone()
+	replace(ta, c.a, subA)
+	replace(tb, c.b, subB)
+	resA, err := newMutation(Splice, ta, subA)
+	if err != nil {
+		return nil, nil, err
+	}
+	resB, err := newMutation(Splice, tb, subB)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resA, resB, nil
+}
+
+// newMutation checks the tree mutated by op at node, and derives it again.
+func newMutation(op string, t *schemas.DerivationTree, node *schemas.TreeNode) (*Mutation, error) {
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("%s made an invalid derivation: %w", op, err)
+	}
+	res, err := t.Derivation()
+	if err != nil {
+		return nil, err
+	}
+	return &Mutation{
+		Derivation: res,
+		Operator:   op,
+		Node:       node.Symbol.GetID(),
+		Production: production(node),
+	}, nil
+}
+
+// generate derives a new subtree from the grammar node symbol.
+func (m *Mutator) generate(symbol *schemas.Node) (*schemas.TreeNode, error) {
+	ctx, err := schemas.NewContext(symbol.GetGrammar(), symbol.GetID(), m.options.Context, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Rand = rand.New(rand.NewSource(m.rand.Int63()))
+	if m.options.Setup != nil {
+		m.options.Setup(ctx)
+	}
+	if _, err := schemas.Generate(ctx, m.chain); err != nil {
+		return nil, fmt.Errorf("generating %s: %w", symbol.GetID(), err)
+	}
+	return ctx.Result.Tree().Root, nil
+}
+
+// pick returns one of the candidates at random, or ErrNoCandidate. With a Scheduler,
+// the production mutated is chosen first, productionOf telling the one of a candidate.
+func pick[T any](m *Mutator, candidates []T, productionOf func(T) string) (T, error) {
+	var res T
+	if len(candidates) == 0 {
+		return res, ErrNoCandidate
+	}
+	if m.options.Scheduler != nil {
+		productions := make([]string, 0)
+		for _, c := range candidates {
+			if p := productionOf(c); !slices.Contains(productions, p) {
+				productions = append(productions, p)
+			}
+		}
+		chosen := m.options.Scheduler.choose(m.op, productions)
+		candidates = slices.DeleteFunc(slices.Clone(candidates), func(c T) bool {
+			return productionOf(c) != chosen
+		})
+	}
+	return candidates[m.rand.Intn(len(candidates))], nil
+}
+
+// production returns the name of the production n belongs to.
+func production(n *schemas.TreeNode) string {
+	for ; n != nil; n = n.Parent {
+		if n.Type == schemas.GrammarProduction {
+			return n.Production()
+		}
+	}
+	return ""
+}
+
+// nodes lists the nodes of the tree for which match holds, in preorder.
+func nodes(t *schemas.DerivationTree, match func(n *schemas.TreeNode) bool) []*schemas.TreeNode {
+	res := make([]*schemas.TreeNode, 0)
+	t.Walk(func(n *schemas.TreeNode) bool {
+		if match(n) {
+			res = append(res, n)
+		}
+		return true
+	})
+	return res
+}
+
+// replace puts the subtree n in place of old in the tree.
+func replace(t *schemas.DerivationTree, old, n *schemas.TreeNode) {
+	n.Parent = old.Parent
+	if old.Parent == nil {
+		t.Root = n
+		return
+	}
+	i := slices.Index(old.Parent.Children, old)
+	old.Parent.Children[i] = n
+}
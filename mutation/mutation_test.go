@@ -0,0 +1,303 @@
+package mutation_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/mutation"
+	"github.com/CUHK-SE-Group/generic-generator/parser"
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func newChain(t *testing.T) *schemas.Chain {
+	chain, err := schemas.CreateChain("mutation", &schemas.BacktrackHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{},
+		&schemas.OrHandler{}, &schemas.RepHandler{Probability: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+// generate derives n inputs of testdata/list.ebnf.
+func generate(t *testing.T, chain *schemas.Chain, n int) []*schemas.Derivation {
+	return generateFrom(t, chain, "./testdata/list.ebnf", n)
+}
+
+// generateFrom derives n inputs of the grammar file, whose start symbol is list.
+func generateFrom(t *testing.T, chain *schemas.Chain, file string, n int) []*schemas.Derivation {
+	g, err := parser.Parse(file, "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make([]*schemas.Derivation, 0, n)
+	for i := 0; i < n; i++ {
+		ctx, err := schemas.NewContext(g, "list", context.Background(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Rand = rand.New(rand.NewSource(int64(i)))
+		if _, err := schemas.Generate(ctx, chain); err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, ctx.Result)
+	}
+	return res
+}
+
+// list consumes a text of testdata/list.ebnf from s.
+func list(s string) (string, bool) {
+	if !strings.HasPrefix(s, "[") {
+		return s, false
+	}
+	s = s[1:]
+	for {
+		switch {
+		case strings.HasPrefix(s, "]"):
+			return s[1:], true
+		case strings.HasPrefix(s, "a"), strings.HasPrefix(s, "b"):
+			s = s[1:]
+		default:
+			var ok bool
+			if s, ok = list(s); !ok {
+				return s, false
+			}
+		}
+	}
+}
+
+func depth(s string) int {
+	res, cur := 0, 0
+	for _, c := range s {
+		switch c {
+		case '[':
+			cur++
+			res = max(res, cur)
+		case ']':
+			cur--
+		}
+	}
+	return res
+}
+
+func TestOperators(t *testing.T) {
+	chain := newChain(t)
+	corpus := mutation.NewCorpus()
+	for _, d := range generate(t, chain, 10) {
+		if err := corpus.Add(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := mutation.NewMutator(chain, mutation.WithCorpus(corpus), mutation.WithSeed(1))
+	applied := make(map[string]int)
+	for _, d := range generate(t, chain, 30) {
+		before := d.GetResult(nil)
+		for _, op := range mutation.Operators {
+			res, err := m.Apply(d, op)
+			if errors.Is(err, mutation.ErrNoCandidate) {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", op, err)
+			}
+			applied[op]++
+			text := res.Derivation.GetResult(nil)
+			tree := res.Derivation.Tree()
+			if rest, ok := list(text); !ok || rest != "" || tree.Text != text {
+				t.Fatalf("%s made %q of %q", op, text, before)
+			}
+			if err := tree.Validate(); err != nil {
+				t.Fatalf("%s: %v", op, err)
+			}
+			if res.Production == "" {
+				t.Fatalf("%s: the production mutated is not reported", op)
+			}
+			switch op {
+			case mutation.Duplicate:
+				if len(text) <= len(before) {
+					t.Fatalf("duplicate made %q of %q", text, before)
+				}
+			case mutation.Remove:
+				if len(text) >= len(before) {
+					t.Fatalf("remove made %q of %q", text, before)
+				}
+			case mutation.Recurse:
+				if depth(text) <= depth(before) {
+					t.Fatalf("recurse made %q of %q", text, before)
+				}
+			}
+		}
+		if d.GetResult(nil) != before {
+			t.Fatalf("the mutated derivation changed")
+		}
+	}
+	for _, op := range mutation.Operators {
+		if applied[op] == 0 {
+			t.Errorf("%s never applied", op)
+		}
+	}
+}
+
+func TestOperatorsRegexTerminal(t *testing.T) {
+	chain := newChain(t)
+	words := regexp.MustCompile(`[a-z]{5}`)
+	corpus := mutation.NewCorpus()
+	m := mutation.NewMutator(chain, mutation.WithCorpus(corpus), mutation.WithSeed(1))
+	applied := 0
+	for _, d := range generateFrom(t, chain, "./testdata/words.ebnf", 30) {
+		before := d.GetResult(nil)
+		if err := corpus.Add(d); err != nil {
+			t.Fatal(err)
+		}
+		if tree := d.Tree(); tree.Text != before {
+			t.Fatalf("the tree has the text %q, the derivation %q", tree.Text, before)
+		}
+		// the words are recorded, the mutations keep them as they are: the words of the
+		// input are all left by Duplicate, which may derive a new one, and Remove adds none
+		for _, op := range []string{mutation.Duplicate, mutation.Remove} {
+			res, err := m.Apply(d, op)
+			if errors.Is(err, mutation.ErrNoCandidate) {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", op, err)
+			}
+			applied++
+			text := res.Derivation.GetResult(nil)
+			from, to := before, text
+			if op == mutation.Remove {
+				from, to = text, before
+			}
+			for _, w := range words.FindAllString(from, -1) {
+				if !strings.Contains(to, w) {
+					t.Fatalf("%s made %q of %q", op, text, before)
+				}
+			}
+		}
+	}
+	if applied == 0 {
+		t.Fatal("no mutation applied")
+	}
+	for _, n := range corpus.Subtrees("word") {
+		if !words.MatchString(n.Text()) {
+			t.Errorf("the corpus has the word %q", n.Text())
+		}
+	}
+}
+
+func TestMutate(t *testing.T) {
+	chain := newChain(t)
+	d := generate(t, chain, 1)[0]
+	m := mutation.NewMutator(chain, mutation.WithOperators(mutation.Remove, mutation.Duplicate))
+	for i := 0; i < 20; i++ {
+		res, err := m.Mutate(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Operator != mutation.Remove && res.Operator != mutation.Duplicate {
+			t.Fatalf("got %s", res.Operator)
+		}
+		d = res.Derivation
+	}
+	if _, err := m.Apply(d, "unknown"); !errors.Is(err, mutation.ErrUnknownOperator) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestCrossover(t *testing.T) {
+	chain := newChain(t)
+	ds := generate(t, chain, 20)
+	m := mutation.NewMutator(chain, mutation.WithSeed(1))
+	crossed := 0
+	for i := 0; i+1 < len(ds); i++ {
+		a, b := ds[i], ds[i+1]
+		x, y, err := m.Crossover(a, b)
+		if errors.Is(err, mutation.ErrNoCandidate) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		crossed++
+		for _, o := range []*mutation.Mutation{x, y} {
+			if rest, ok := list(o.Derivation.GetResult(nil)); !ok || rest != "" || o.Operator != mutation.Splice {
+				t.Fatalf("crossing %q and %q made %q", a.GetResult(nil), b.GetResult(nil), o.Derivation.GetResult(nil))
+			}
+		}
+		// the subtrees are swapped, so the offspring have the texts of their parents between them
+		if len(x.Derivation.GetResult(nil))+len(y.Derivation.GetResult(nil)) != len(a.GetResult(nil))+len(b.GetResult(nil)) {
+			t.Fatalf("crossing %q and %q made %q and %q", a.GetResult(nil), b.GetResult(nil), x.Derivation.GetResult(nil), y.Derivation.GetResult(nil))
+		}
+	}
+	if crossed == 0 {
+		t.Fatal("no derivations crossed")
+	}
+}
+
+func TestCorpusSave(t *testing.T) {
+	chain := newChain(t)
+	corpus := mutation.NewCorpus()
+	for _, d := range generate(t, chain, 5) {
+		if err := corpus.Add(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(t.TempDir(), "corpus.json")
+	if err := corpus.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	g, err := parser.Parse("./testdata/list.ebnf", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := mutation.NewCorpus()
+	if err := loaded.Load(g, file); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != corpus.Len() {
+		t.Fatalf("loaded %d derivations of %d", loaded.Len(), corpus.Len())
+	}
+	for _, p := range []string{"list", "item"} {
+		want, got := corpus.Subtrees(p), loaded.Subtrees(p)
+		if len(got) != len(want) {
+			t.Fatalf("loaded %d subtrees of %s, want %d", len(got), p, len(want))
+		}
+		for i := range got {
+			if got[i].Text() != want[i].Text() {
+				t.Errorf("loaded %q, want %q", got[i].Text(), want[i].Text())
+			}
+		}
+	}
+	m := mutation.NewMutator(chain, mutation.WithCorpus(loaded), mutation.WithSeed(1))
+	if _, err := m.Apply(generate(t, chain, 1)[0], mutation.Splice); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCorpusDedup(t *testing.T) {
+	chain := newChain(t)
+	corpus := mutation.NewCorpus()
+	ds := generate(t, chain, 10)
+	for _, d := range append(ds, ds...) {
+		if err := corpus.Add(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lists := len(corpus.Subtrees("list"))
+	if n := corpus.Dedup(func(a, b *schemas.DerivationTree) bool {
+		return schemas.TreeEditDistance(a, b) == 0
+	}); n < 10 || corpus.Len() != 20-n {
+		t.Fatalf("removed %d derivations, %d left", n, corpus.Len())
+	}
+	if len(corpus.Subtrees("list")) > lists/2 {
+		t.Errorf("the subtrees of the removed derivations are still indexed")
+	}
+	before := corpus.Len()
+	if n := corpus.Dedup(func(a, b *schemas.DerivationTree) bool { return true }); n != before-1 || corpus.Len() != 1 {
+		t.Errorf("removed %d derivations, %d left", n, corpus.Len())
+	}
+}
@@ -0,0 +1,271 @@
+package mutation
+
+import (
+	"slices"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+const (
+	Regenerate = "regenerate"
+	Replace    = "replace"
+	Duplicate  = "duplicate"
+	Remove     = "remove"
+	Swap       = "swap"
+	Recurse    = "recurse"
+	Splice     = "splice"
+)
+
+// Operators are the names of all the operators, see Mutator.Apply.
+var Operators = []string{Regenerate, Replace, Duplicate, Remove, Swap, Recurse, Splice}
+
+// operator mutates the tree in place and returns the node it mutated.
+type operator func(m *Mutator, t *schemas.DerivationTree) (*schemas.TreeNode, error)
+
+var operators = map[string]operator{
+	Regenerate: regenerate,
+	Replace:    replaceFromCorpus,
+	Duplicate:  duplicate,
+	Remove:     remove,
+	Swap:       swap,
+	Recurse:    recurse,
+	Splice:     splice,
+}
+
+// regenerate derives a production again from scratch.
+func regenerate(m *Mutator, t *schemas.DerivationTree) (*schemas.TreeNode, error) {
+	n, err := pick(m, nodes(t, func(n *schemas.TreeNode) bool {
+		return n.Type == schemas.GrammarProduction
+	}), production)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := m.generate(n.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	replace(t, n, sub)
+	return sub, nil
+}
+
+// replaceFromCorpus replaces a production by a subtree of the same production taken from the corpus, with another text.
+func replaceFromCorpus(m *Mutator, t *schemas.DerivationTree) (*schemas.TreeNode, error) {
+	if m.options.Corpus == nil {
+		return nil, ErrNoCandidate
+	}
+	type candidate struct {
+		node, sub *schemas.TreeNode
+	}
+	candidates := make([]candidate, 0)
+	for _, n := range nodes(t, func(n *schemas.TreeNode) bool { return n.Type == schemas.GrammarProduction }) {
+		for _, sub := range m.options.Corpus.Subtrees(n.Symbol.GetID()) {
+			if sub.Text() != n.Text() {
+				candidates = append(candidates, candidate{n, sub})
+			}
+		}
+	}
+	c, err := pick(m, candidates, func(c candidate) string {
+		return production(c.node)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sub := c.sub.Clone()
+	replace(t, c.node, sub)
+	return sub, nil
+}
+
+// items splits the children of a repetition into its items.
+func items(n *schemas.TreeNode) [][]*schemas.TreeNode {
+	size := len(n.Symbol.GetSymbols())
+	res := make([][]*schemas.TreeNode, 0)
+	if size == 0 {
+		return res
+	}
+	for i := 0; i+size <= len(n.Children); i += size {
+		res = append(res, n.Children[i:i+size])
+	}
+	return res
+}
+
+func repetition(n *schemas.TreeNode) bool {
+	return !n.Leaf && n.Type&(schemas.GrammarREP|schemas.GrammarPLUS|schemas.GrammarOptional|schemas.GrammarEXT) != 0
+}
+
+// duplicate repeats an item of a repetition once more, or derives one for an empty repetition or option.
+func duplicate(m *Mutator, t *schemas.DerivationTree) (*schemas.TreeNode, error) {
+	n, err := pick(m, nodes(t, func(n *schemas.TreeNode) bool {
+		if !repetition(n) || len(n.Symbol.GetSymbols()) == 0 {
+			return false
+		}
+		optional := n.Type == schemas.GrammarOptional || n.Type == schemas.GrammarEXT
+		return !optional || len(n.Children) == 0
+	}), production)
+	if err != nil {
+		return nil, err
+	}
+	item := make([]*schemas.TreeNode, 0)
+	at := 0
+	if all := items(n); len(all) > 0 {
+		i := m.rand.Intn(len(all))
+		for _, c := range all[i] {
+			item = append(item, c.Clone())
+		}
+		at = (i + 1) * len(all[i])
+	} else {
+		// the children of the grammar node come last first
+		symbols := n.Symbol.GetSymbols()
+		for i := len(symbols) - 1; i >= 0; i-- {
+			sub, err := m.generate(symbols[i])
+			if err != nil {
+				return nil, err
+			}
+			item = append(item, sub)
+		}
+	}
+	for _, c := range item {
+		c.Parent = n
+	}
+	n.Children = slices.Insert(n.Children, at, item...)
+	return n, nil
+}
+
+// remove removes an item of a repetition or an option, a PLUS node keeps one.
+func remove(m *Mutator, t *schemas.DerivationTree) (*schemas.TreeNode, error) {
+	n, err := pick(m, nodes(t, func(n *schemas.TreeNode) bool {
+		if !repetition(n) {
+			return false
+		}
+		min := 1
+		if n.Type == schemas.GrammarPLUS {
+			min = 2
+		}
+		return len(items(n)) >= min
+	}), production)
+	if err != nil {
+		return nil, err
+	}
+	size := len(n.Symbol.GetSymbols())
+	i := m.rand.Intn(len(items(n)))
+	n.Children = slices.Delete(n.Children, i*size, (i+1)*size)
+	return n, nil
+}
+
+// swap derives another alternative of an OR node.
+func swap(m *Mutator, t *schemas.DerivationTree) (*schemas.TreeNode, error) {
+	others := func(n *schemas.TreeNode) []*schemas.Node {
+		res := make([]*schemas.Node, 0)
+		if n.Leaf || len(n.Children) != 1 {
+			return res
+		}
+		for _, alt := range n.Symbol.GetSymbols() {
+			if alt.GetID() != n.Children[0].Symbol.GetID() {
+				res = append(res, alt)
+			}
+		}
+		return res
+	}
+	n, err := pick(m, nodes(t, func(n *schemas.TreeNode) bool {
+		return n.Type == schemas.GrammarOR && len(others(n)) > 0
+	}), production)
+	if err != nil {
+		return nil, err
+	}
+	alts := others(n)
+	alt := alts[m.rand.Intn(len(alts))]
+	sub, err := m.generate(alt)
+	if err != nil {
+		return nil, err
+	}
+	replace(t, n.Children[0], sub)
+	return n, nil
+}
+
+// recurse repeats a recursion of a production, like Nautilus: if the production A derives
+// a ... A ... b, the inner A is derived again as a ... A ... b, up to MaxRecursion times.
+func recurse(m *Mutator, t *schemas.DerivationTree) (*schemas.TreeNode, error) {
+	type recursion struct {
+		outer, inner *schemas.TreeNode
+	}
+	candidates := make([]recursion, 0)
+	for _, inner := range nodes(t, func(n *schemas.TreeNode) bool { return n.Type == schemas.GrammarProduction }) {
+		for outer := inner.Parent; outer != nil; outer = outer.Parent {
+			if outer.Type == schemas.GrammarProduction && outer.Symbol.GetID() == inner.Symbol.GetID() {
+				candidates = append(candidates, recursion{outer, inner})
+			}
+		}
+	}
+	r, err := pick(m, candidates, func(r recursion) string {
+		return production(r.outer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// path of the inner node from the outer one, as indices of children
+	path := make([]int, 0)
+	for n := r.inner; n != r.outer; n = n.Parent {
+		path = append(path, slices.Index(n.Parent.Children, n))
+	}
+	slices.Reverse(path)
+
+	res := r.inner.Clone()
+	// the outer node is kept, and repeated k times around the inner one
+	for k := m.rand.Intn(m.options.MaxRecursion) + 1; k >= 0; k-- {
+		c := r.outer.Clone()
+		at := c
+		for _, i := range path {
+			at = at.Children[i]
+		}
+		replace(nil, at, res)
+		res = c
+	}
+	replace(t, r.outer, res)
+	return res, nil
+}
+
+// splice crosses the derivation with one of the corpus, like Grammarinator and Superion: a
+// production is replaced by a subtree of the same production of the other derivation, with another text.
+func splice(m *Mutator, t *schemas.DerivationTree) (*schemas.TreeNode, error) {
+	if m.options.Corpus == nil {
+		return nil, ErrNoCandidate
+	}
+	parents := make([]*schemas.DerivationTree, 0)
+	for _, p := range m.options.Corpus.trees {
+		if len(crossings(t, p)) > 0 {
+			parents = append(parents, p)
+		}
+	}
+	if len(parents) == 0 {
+		return nil, ErrNoCandidate
+	}
+	c, err := pick(m, crossings(t, parents[m.rand.Intn(len(parents))]), func(c crossing) string {
+		return production(c.a)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sub := c.b.Clone()
+	replace(t, c.a, sub)
+	return sub, nil
+}
+
+// crossing is a pair of subtrees of two derivations derived from the same production, with different texts.
+type crossing struct {
+	a, b *schemas.TreeNode
+}
+
+func crossings(a, b *schemas.DerivationTree) []crossing {
+	index := make(map[string][]*schemas.TreeNode)
+	for _, n := range nodes(b, func(n *schemas.TreeNode) bool { return n.Type == schemas.GrammarProduction }) {
+		index[n.Symbol.GetID()] = append(index[n.Symbol.GetID()], n)
+	}
+	res := make([]crossing, 0)
+	for _, n := range nodes(a, func(n *schemas.TreeNode) bool { return n.Type == schemas.GrammarProduction }) {
+		for _, sub := range index[n.Symbol.GetID()] {
+			if sub.Text() != n.Text() {
+				res = append(res, crossing{n, sub})
+			}
+		}
+	}
+	return res
+}
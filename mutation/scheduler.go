@@ -0,0 +1,243 @@
+package mutation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// Policies of a Scheduler.
+const (
+	UCB      = "ucb"      // UCB1: the best mean reward plus a bonus for the arms tried less
+	Thompson = "thompson" // Thompson sampling: a draw from the Beta posterior of the reward of every arm
+)
+
+// Stats counts the outcomes of the mutations made by an operator, or by an operator in a production.
+type Stats struct {
+	Trials   int `json:"trials"`
+	Coverage int `json:"coverage"` // mutations which covered new code
+	Crashes  int `json:"crashes"`
+	Rewards  int `json:"rewards"` // mutations which covered new code or crashed the target
+}
+
+type schedulerStats struct {
+	Operators   map[string]*Stats            `json:"operators"`
+	Productions map[string]map[string]*Stats `json:"productions"` // by operator, then production
+}
+
+// Scheduler chooses the operators of a Mutator, and the productions they mutate, with a
+// multi-armed bandit rewarded by the mutations which covered new code or crashed the target,
+// see Report. Its statistics can be saved for the next run. It is safe for concurrent use.
+type Scheduler struct {
+	Policy      string  // UCB by default
+	Exploration float64 // weight of the bonus of UCB, √2 by default
+	Seed        int64   // seed of Thompson sampling, random if 0
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	stats schedulerStats
+}
+
+func (s *Scheduler) init() {
+	if s.rand == nil {
+		seed := s.Seed
+		if seed == 0 {
+			seed = rand.Int63()
+		}
+		s.rand = rand.New(rand.NewSource(seed))
+	}
+	if s.stats.Operators == nil {
+		s.stats.Operators = make(map[string]*Stats)
+	}
+	if s.stats.Productions == nil {
+		s.stats.Productions = make(map[string]map[string]*Stats)
+	}
+}
+
+// rank sorts the arms by the policy, best first; arms never tried come first, at random.
+func (s *Scheduler) rank(arms []string, stats func(arm string) Stats) []string {
+	total := 0
+	for _, arm := range arms {
+		total += stats(arm).Trials
+	}
+	score := make(map[string]float64, len(arms))
+	for _, arm := range arms {
+		st := stats(arm)
+		switch {
+		case st.Trials == 0:
+			score[arm] = math.Inf(1)
+		case s.Policy == Thompson:
+			score[arm] = beta(s.rand, float64(1+st.Rewards), float64(1+st.Trials-st.Rewards))
+		default:
+			c := s.Exploration
+			if c == 0 {
+				c = math.Sqrt2
+			}
+			score[arm] = float64(st.Rewards)/float64(st.Trials) + c*math.Sqrt(math.Log(float64(total))/float64(st.Trials))
+		}
+	}
+	res := slices.Clone(arms)
+	s.rand.Shuffle(len(res), func(i, j int) {
+		res[i], res[j] = res[j], res[i]
+	})
+	slices.SortStableFunc(res, func(a, b string) int {
+		switch {
+		case score[a] > score[b]:
+			return -1
+		case score[a] < score[b]:
+			return 1
+		}
+		return 0
+	})
+	return res
+}
+
+// order sorts the operators to try, best first.
+func (s *Scheduler) order(operators []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	return s.rank(operators, func(op string) Stats {
+		return s.get(op, "")
+	})
+}
+
+// choose returns the production the operator op mutates next.
+func (s *Scheduler) choose(op string, productions []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	return s.rank(productions, func(production string) Stats {
+		return s.get(op, production)
+	})[0]
+}
+
+func (s *Scheduler) get(op, production string) Stats {
+	st := s.stats.Operators[op]
+	if production != "" {
+		st = s.stats.Productions[op][production]
+	}
+	if st == nil {
+		return Stats{}
+	}
+	return *st
+}
+
+// Stats returns the statistics of the operator op, of all its productions if production is "".
+func (s *Scheduler) Stats(op, production string) Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(op, production)
+}
+
+// Update counts a mutation made by op in the production, and whether it covered new code or crashed the target.
+func (s *Scheduler) Update(op, production string, coverage, crash bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	if s.stats.Productions[op] == nil {
+		s.stats.Productions[op] = make(map[string]*Stats)
+	}
+	for _, arm := range []struct {
+		stats map[string]*Stats
+		key   string
+	}{{s.stats.Operators, op}, {s.stats.Productions[op], production}} {
+		st := arm.stats[arm.key]
+		if st == nil {
+			st = &Stats{}
+			arm.stats[arm.key] = st
+		}
+		st.Trials++
+		if coverage {
+			st.Coverage++
+		}
+		if crash {
+			st.Crashes++
+		}
+		if coverage || crash {
+			st.Rewards++
+		}
+	}
+}
+
+// Report counts the mutation m with the output of the Environment run on it, see schemas.OutputNewCoverage and schemas.OutputCrash.
+func (s *Scheduler) Report(m *Mutation, out schemas.Output) {
+	s.Update(m.Operator, m.Production, reported(out[schemas.OutputNewCoverage]), reported(out[schemas.OutputCrash]))
+}
+
+// reported tells whether an output value is set: true, a positive number or a non-empty string.
+func reported(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case int:
+		return v > 0
+	case int64:
+		return v > 0
+	case float64:
+		return v > 0
+	case string:
+		return v != ""
+	}
+	return false
+}
+
+// Save writes the statistics to filename in JSON.
+func (s *Scheduler) Save(filename string) error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.stats, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// Load reads the statistics written by Save, they replace the ones of the Scheduler.
+func (s *Scheduler) Load(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	stats := schedulerStats{}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return fmt.Errorf("loading the statistics of %s: %w", filename, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = stats
+	s.init()
+	return nil
+}
+
+// beta draws from the Beta(a, b) distribution.
+func beta(r *rand.Rand, a, b float64) float64 {
+	x := gamma(r, a)
+	return x / (x + gamma(r, b))
+}
+
+// gamma draws from the Gamma(k, 1) distribution, by the method of Marsaglia and Tsang.
+func gamma(r *rand.Rand, k float64) float64 {
+	if k < 1 {
+		return gamma(r, k+1) * math.Pow(r.Float64(), 1/k)
+	}
+	d := k - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		if math.Log(r.Float64()) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
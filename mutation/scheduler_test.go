@@ -0,0 +1,64 @@
+package mutation_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/mutation"
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func TestScheduler(t *testing.T) {
+	chain := newChain(t)
+	inputs := generate(t, chain, 10)
+	for _, policy := range []string{mutation.UCB, mutation.Thompson} {
+		s := &mutation.Scheduler{Policy: policy, Seed: 1}
+		m := mutation.NewMutator(chain, mutation.WithScheduler(s), mutation.WithSeed(1))
+		for i := 0; i < 200; i++ {
+			res, err := m.Mutate(inputs[i%len(inputs)])
+			if err != nil {
+				t.Fatal(err)
+			}
+			// only the swaps find new code
+			s.Report(res, schemas.Output{schemas.OutputNewCoverage: res.Operator == mutation.Swap})
+		}
+		swap := s.Stats(mutation.Swap, "")
+		if swap.Rewards != swap.Trials {
+			t.Errorf("%s: got %+v", policy, swap)
+		}
+		for _, op := range mutation.Operators {
+			if st := s.Stats(op, ""); op != mutation.Swap && st.Trials >= swap.Trials {
+				t.Errorf("%s: %s was tried %d times, swap %d times", policy, op, st.Trials, swap.Trials)
+			}
+		}
+		trials := 0
+		for _, p := range []string{"list", "item"} {
+			trials += s.Stats(mutation.Swap, p).Trials
+		}
+		if trials != swap.Trials {
+			t.Errorf("%s: the swaps of the productions are %d, not %d", policy, trials, swap.Trials)
+		}
+	}
+}
+
+func TestSchedulerSave(t *testing.T) {
+	s := &mutation.Scheduler{}
+	s.Update(mutation.Swap, "item", true, false)
+	s.Update(mutation.Swap, "item", false, true)
+	s.Update(mutation.Remove, "list", false, false)
+	filename := filepath.Join(t.TempDir(), "stats.json")
+	if err := s.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &mutation.Scheduler{}
+	if err := loaded.Load(filename); err != nil {
+		t.Fatal(err)
+	}
+	want := mutation.Stats{Trials: 2, Coverage: 1, Crashes: 1, Rewards: 2}
+	if got := loaded.Stats(mutation.Swap, "item"); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := loaded.Stats(mutation.Remove, ""); got.Trials != 1 || got.Rewards != 0 {
+		t.Errorf("got %+v", got)
+	}
+}
@@ -8,9 +8,16 @@ import (
 	"github.com/antlr4-go/antlr/v4"
 	"log/slog"
 	"os"
+	"regexp"
 	"strconv"
 )
 
+// annotation marks the production it precedes as lexical or syntactic, e.g.
+//
+//	// @lexical
+//	name = letter, {letter | digit};
+var annotation = regexp.MustCompile(`(?m)^[ \t]*//[ \t]*@(lexical|syntactic)[ \t]*\r?\n\s*([_\p{L}][_\p{L}\p{N}]*)\s*(?:=|::=)`)
+
 type ebnfListener struct {
 	*ebnf.BaseEBNFParserListener
 	stack             []*schemas.Node
@@ -208,16 +215,26 @@ func (l *ebnfListener) ExitBRACKET(c *ebnf.BRACKETContext) {
 	l.pop()
 }
 
+// Parse builds the grammar of an EBNF file. The productions with upper-case names, like
+// IDENTIFIER, are lexical, unless a "// @syntactic" comment precedes them; "// @lexical"
+// marks the others, see schemas.Grammar.InferLexical.
 func Parse(file string, startSym string) (*schemas.Grammar, error) {
-	is, err := antlr.NewFileStream(file)
+	data, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
+	is := antlr.NewInputStream(string(data))
 	lexer := ebnf.NewEBNFLexer(is)
 	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
 	parser := ebnf.NewEBNFParser(stream)
 	listener := newEbnfListener(startSym)
 	antlr.ParseTreeWalkerDefault.Walk(listener, parser.Ebnf())
 
+	listener.grammar.InferLexical()
+	for _, m := range annotation.FindAllStringSubmatch(string(data), -1) {
+		if err := listener.grammar.SetLexical(m[1] == "lexical", m[2]); err != nil {
+			return nil, err
+		}
+	}
 	return listener.grammar, nil
 }
@@ -54,3 +54,17 @@ func TestParseCypher(t *testing.T) {
 func TestParseTinyC(t *testing.T) {
 	parseAndVisualize("./testdata/complete/tinyc.ebnf")
 }
+
+func TestParseLexical(t *testing.T) {
+	g, err := Parse("./testdata/lexical/lexical.ebnf", "program")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, lexical := range map[string]bool{
+		"program": false, "statement": false, "SP": false, "name": true, "letter": false, "NUMBER": true,
+	} {
+		if g.GetNode(name).IsLexical() != lexical {
+			t.Errorf("%s should be lexical: %v", name, lexical)
+		}
+	}
+}
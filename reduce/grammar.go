@@ -0,0 +1,312 @@
+package reduce
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// Kinds of a Removal.
+const (
+	RemovedProduction  = "production"
+	RemovedAlternative = "alternative"
+)
+
+// Generation is how ReduceGrammar generates the inputs of a grammar: Samples inputs,
+// seeded from Seed on, of which at least a Rate must trigger the bug.
+type Generation struct {
+	Chain   *schemas.Chain
+	Samples int     // 20 by default
+	Rate    float64 // the rate of the grammar reduced by default
+	Seed    int64
+	Setup   func(*schemas.Context) // called on the Context of every generation, e.g. to set a Budget
+}
+
+// Removal is a part of the grammar ReduceGrammar removed.
+type Removal struct {
+	Kind       string // RemovedProduction or RemovedAlternative
+	Production string // the production removed, or the one of the alternative
+	ID         string // ID of the grammar node removed
+	Content    string
+}
+
+// GrammarResult is the smallest grammar found by ReduceGrammar.
+type GrammarResult struct {
+	Grammar *schemas.Grammar
+	Removed []Removal
+	Rate    float64 // rate of the inputs of the grammar which trigger the bug
+	Tests   int     // calls of the oracle
+}
+
+// alternative is the edge from a GrammarOR node to one of its alternatives.
+type alternative struct {
+	or, child string
+}
+
+type grammarReducer struct {
+	grammar *schemas.Grammar
+	gen     Generation
+	oracle  Oracle
+	options Options
+	tests   int
+}
+
+// ReduceGrammar removes the productions, then the alternatives, of the grammar which are
+// not needed to trigger the bug, by delta debugging: a part is removed if the inputs
+// generated from the grammar without it still trigger the bug at the rate of gen. Removing
+// a production removes the alternatives which refer to it, and the parts no longer
+// reachable from the start symbol are removed as well. g is left unchanged.
+func ReduceGrammar(g *schemas.Grammar, gen Generation, oracle Oracle, opts ...Option) (*GrammarResult, error) {
+	if gen.Samples <= 0 {
+		gen.Samples = 20
+	}
+	r := &grammarReducer{grammar: g, gen: gen, oracle: oracle, options: newOptions(opts)}
+	start := r.rate(g)
+	if start == 0 || start < gen.Rate {
+		return nil, ErrNotReproduced
+	}
+	if r.gen.Rate == 0 {
+		r.gen.Rate = start
+	}
+	productions := make([]string, 0)
+	for _, id := range ids(g) {
+		if n := g.GetNode(id); n.GetType() == schemas.GrammarProduction && id != g.GetStartSym() {
+			productions = append(productions, id)
+		}
+	}
+	keep := ddmin(len(productions), func(subsets [][]int) int {
+		return slices.IndexFunc(subsets, func(keep []int) bool {
+			return r.test(without(productions, keep), nil)
+		})
+	})
+	removed := without(productions, keep)
+	current, _ := r.build(removed, nil)
+	alternatives := make([]alternative, 0)
+	for _, id := range ids(current) {
+		if n := current.GetNode(id); n.GetType() == schemas.GrammarOR {
+			for _, c := range n.GetSymbols() {
+				alternatives = append(alternatives, alternative{or: id, child: c.GetID()})
+			}
+		}
+	}
+	keep = ddmin(len(alternatives), func(subsets [][]int) int {
+		return slices.IndexFunc(subsets, func(keep []int) bool {
+			return r.test(removed, without(alternatives, keep))
+		})
+	})
+	res, _ := r.build(removed, without(alternatives, keep))
+	return &GrammarResult{
+		Grammar: res,
+		Removed: removals(g, res),
+		Rate:    r.rate(res),
+		Tests:   r.tests,
+	}, nil
+}
+
+func (r *grammarReducer) stopped() bool {
+	return r.options.Context.Err() != nil || r.options.MaxTests > 0 && r.tests >= r.options.MaxTests
+}
+
+// test tells whether the grammar without the productions and alternatives removed triggers the bug at the rate wanted.
+func (r *grammarReducer) test(productions []string, alternatives []alternative) bool {
+	if r.stopped() {
+		return false
+	}
+	g, ok := r.build(productions, alternatives)
+	return ok && r.rate(g) >= r.gen.Rate
+}
+
+// rate returns the rate of the inputs generated from g which trigger the bug, the
+// generations which fail count as misses. It is 0 once the reduction is stopped.
+func (r *grammarReducer) rate(g *schemas.Grammar) float64 {
+	inputs := make([]string, 0, r.gen.Samples)
+	for i := 0; i < r.gen.Samples; i++ {
+		if r.stopped() {
+			return 0
+		}
+		ctx, err := schemas.NewContext(g, g.GetStartSym(), r.options.Context, nil, nil)
+		if err != nil {
+			return 0
+		}
+		ctx.Rand = rand.New(rand.NewSource(r.gen.Seed + int64(i)))
+		if r.gen.Setup != nil {
+			r.gen.Setup(ctx)
+		}
+		if _, err := schemas.Generate(ctx, r.gen.Chain); err == nil {
+			inputs = append(inputs, r.options.Render(ctx.Result))
+		}
+	}
+	hits := 0
+	if runner := r.options.Runner; runner != nil {
+		calls, _ := runner.Stats()
+		for _, verdict := range runner.Run(r.options.Context, inputs) {
+			if verdict {
+				hits++
+			}
+		}
+		after, _ := runner.Stats()
+		r.tests += after - calls
+	} else {
+		for _, input := range inputs {
+			if r.stopped() {
+				return 0
+			}
+			r.tests++
+			if r.oracle(input) {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(r.gen.Samples)
+}
+
+// build copies the grammar without the productions and alternatives removed, nor the nodes
+// no longer reachable, and updates its distances to terminals. It reports false if a node
+// reachable from the start symbol of the copy derives no input, e.g. an identifier without
+// production or a choice without alternative.
+func (r *grammarReducer) build(productions []string, alternatives []alternative) (*schemas.Grammar, bool) {
+	g := r.grammar.Clone()
+	for _, id := range ids(g) {
+		n := g.GetNode(id)
+		if n.GetType() != schemas.GrammarOR {
+			continue
+		}
+		for _, c := range n.GetSymbols() {
+			if slices.Contains(alternatives, alternative{or: id, child: c.GetID()}) || refers(c, productions) {
+				n.RemoveSymbol(c)
+			}
+		}
+	}
+	for _, p := range productions {
+		g.RemoveNode(p)
+	}
+	terminating := productive(g)
+	reachable := make(map[string]bool)
+	ok := true
+	var walk func(n *schemas.Node)
+	walk = func(n *schemas.Node) {
+		if reachable[n.GetID()] {
+			return
+		}
+		reachable[n.GetID()] = true
+		ok = ok && terminating[n.GetID()]
+		if n.GetType() == schemas.GrammarID {
+			if p := g.GetNode(n.GetContent()); p != nil {
+				walk(p)
+			}
+		}
+		for _, c := range n.GetSymbols() {
+			walk(c)
+		}
+	}
+	if start := g.GetNode(g.GetStartSym()); start != nil {
+		walk(start)
+	} else {
+		ok = false
+	}
+	for _, id := range ids(g) {
+		if !reachable[id] {
+			g.RemoveNode(id)
+		}
+	}
+	if ok {
+		g.BuildShortestNotation()
+	}
+	return g, ok
+}
+
+// productive lists the nodes of the grammar which derive an input. The repetitions and
+// options are productive only if their items are, since the generation may repeat them.
+func productive(g *schemas.Grammar) map[string]bool {
+	res := make(map[string]bool)
+	derives := func(c *schemas.Node) bool {
+		return res[c.GetID()]
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, id := range ids(g) {
+			n := g.GetNode(id)
+			if res[id] {
+				continue
+			}
+			switch children := n.GetSymbols(); n.GetType() {
+			case schemas.GrammarID:
+				p := g.GetNode(n.GetContent())
+				res[id] = p != nil && res[p.GetID()]
+			case schemas.GrammarOR:
+				res[id] = slices.ContainsFunc(children, derives)
+			default:
+				res[id] = !slices.ContainsFunc(children, func(c *schemas.Node) bool {
+					return !derives(c)
+				})
+			}
+			changed = changed || res[id]
+		}
+	}
+	return res
+}
+
+// refers tells whether the node refers to one of the productions, without entering the productions it refers to.
+func refers(n *schemas.Node, productions []string) bool {
+	if n.GetType() == schemas.GrammarID {
+		return slices.Contains(productions, n.GetContent())
+	}
+	for _, c := range n.GetSymbols() {
+		if refers(c, productions) {
+			return true
+		}
+	}
+	return false
+}
+
+// removals lists the productions of g which res does not have, then the alternatives
+// res does not have of the productions they both have.
+func removals(g, res *schemas.Grammar) []Removal {
+	out := make([]Removal, 0)
+	for _, id := range ids(g) {
+		if n := g.GetNode(id); n.GetType() == schemas.GrammarProduction && res.GetNode(id) == nil {
+			out = append(out, Removal{Kind: RemovedProduction, Production: id, ID: id, Content: n.GetContent()})
+		}
+	}
+	for _, id := range ids(g) {
+		n, kept := g.GetNode(id), res.GetNode(id)
+		if n.GetType() != schemas.GrammarOR || kept == nil {
+			continue
+		}
+		left := make([]string, 0)
+		for _, c := range kept.GetSymbols() {
+			left = append(left, c.GetID())
+		}
+		production, _, _ := strings.Cut(id, "#")
+		for _, c := range n.GetSymbols() {
+			if !slices.Contains(left, c.GetID()) {
+				out = append(out, Removal{Kind: RemovedAlternative, Production: production, ID: c.GetID(), Content: c.GetContent()})
+			}
+		}
+	}
+	return out
+}
+
+// ids lists the IDs of the nodes of the grammar, sorted.
+func ids(g *schemas.Grammar) []string {
+	res := make([]string, 0)
+	for _, v := range g.GetInternal().GetAllVertices() {
+		res = append(res, v.GetID())
+	}
+	sort.Strings(res)
+	return res
+}
+
+// without returns the units but the ones kept, given as indices.
+func without[T any](units []T, keep []int) []T {
+	res := make([]T, 0)
+	for i, u := range units {
+		if !slices.Contains(keep, i) {
+			res = append(res, u)
+		}
+	}
+	return res
+}
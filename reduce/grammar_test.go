@@ -0,0 +1,103 @@
+package reduce_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/parser"
+	"github.com/CUHK-SE-Group/generic-generator/reduce"
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func exprGeneration(t *testing.T) reduce.Generation {
+	chain, err := schemas.CreateChain("grammar", &schemas.BacktrackHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.ProbabilityHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reduce.Generation{
+		Chain:   chain,
+		Samples: 30,
+		Setup: func(ctx *schemas.Context) {
+			ctx.Budget = schemas.Budget{MaxTokens: 40, MaxRecursion: 2}
+		},
+	}
+}
+
+func parseExpr(t *testing.T) *schemas.Grammar {
+	g, err := parser.Parse("./testdata/expr.ebnf", "expr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.MergeProduction()
+	g.BuildShortestNotation()
+	for _, v := range g.GetInternal().GetAllVertices() {
+		if n := g.GetNode(v.GetID()); n.GetType() == schemas.GrammarREP {
+			n.SetRepeatProb(map[int]float64{0: 0.4, 1: 0.3, 2: 0.3})
+		}
+	}
+	return g
+}
+
+func TestReduceGrammar(t *testing.T) {
+	g := parseExpr(t)
+	nodes := len(g.GetInternal().GetAllVertices())
+	oracle := func(s string) bool { return strings.Contains(s, "*y") }
+	res, err := reduce.ReduceGrammar(g, exprGeneration(t), oracle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.GetInternal().GetAllVertices()) != nodes {
+		t.Fatal("the grammar reduced changed")
+	}
+	removed := make(map[string]bool)
+	for _, r := range res.Removed {
+		name := r.Content
+		if r.Kind == reduce.RemovedProduction {
+			name = r.ID
+		}
+		removed[r.Kind+" "+name] = true
+	}
+	for _, want := range []string{"production number", "alternative 'x'", "alternative '/'"} {
+		if !removed[want] {
+			t.Errorf("%s not removed, got %v", want, res.Removed)
+		}
+	}
+	if removed["alternative 'y'"] || removed["alternative '*'"] {
+		t.Errorf("removed a part needed, got %v", res.Removed)
+	}
+	if res.Rate == 0 || res.Tests == 0 {
+		t.Errorf("got rate %f after %d tests", res.Rate, res.Tests)
+	}
+
+	file := filepath.Join(t.TempDir(), "grammar")
+	if err := res.Grammar.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	reduced := schemas.NewGrammar(schemas.WithLoadFromFile(file))
+	gen := exprGeneration(t)
+	for i := 0; i < 10; i++ {
+		ctx, err := schemas.NewContext(reduced, "expr", context.Background(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Rand = rand.New(rand.NewSource(int64(i)))
+		gen.Setup(ctx)
+		if _, err := schemas.Generate(ctx, gen.Chain); err != nil {
+			t.Fatal(err)
+		}
+		if text := ctx.Result.GetResult(nil); strings.ContainsAny(text, "12x/") {
+			t.Errorf("the reduced grammar generated %q", text)
+		}
+	}
+}
+
+func TestReduceGrammarNotReproduced(t *testing.T) {
+	g := parseExpr(t)
+	if _, err := reduce.ReduceGrammar(g, exprGeneration(t), func(s string) bool { return strings.Contains(s, "z") }); !errors.Is(err, reduce.ErrNotReproduced) {
+		t.Fatalf("got %v", err)
+	}
+}
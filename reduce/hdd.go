@@ -0,0 +1,215 @@
+package reduce
+
+import (
+	"slices"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// Reduce reduces the derivation with the hierarchical delta debugging of Misherghi and Su,
+// like Perses: level by level from the root, the items of the repetitions and options are
+// removed and the productions replaced by their shortest derivations, see
+// Grammar.ShortestDerivation, or by one of their descendants of the same production, as
+// long as the oracle reports the bug, until a pass removes nothing more. Every input tried
+// is grammatical, and shorter than the smallest one found. The grammar needs
+// Grammar.MergeProduction and Grammar.BuildShortestNotation.
+func Reduce(d *schemas.Derivation, oracle Oracle, opts ...Option) (*Result, error) {
+	t := d.Tree()
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	// the text of the leaves, e.g. of the regular expressions, is fixed from now on
+	start, err := t.Derivation()
+	if err != nil {
+		return nil, err
+	}
+	r := newReducer(oracle, opts)
+	r.best = &Result{Derivation: start, Text: r.options.Render(start), Tests: 1}
+	if !oracle(r.best.Text) {
+		return nil, ErrNotReproduced
+	}
+	h := &hdd{reducer: r, shortest: make(map[string]*schemas.TreeNode)}
+	for changed := true; changed && !r.stopped(); {
+		changed = false
+		for level := 0; !r.stopped(); level++ {
+			base := r.best.Derivation
+			units, ok := h.units(base.Tree(), level)
+			if !ok {
+				break
+			}
+			before := r.best.Text
+			if len(units) > 0 {
+				ddmin(len(units), func(subsets [][]int) int {
+					ds := make([]*schemas.Derivation, len(subsets))
+					for i, keep := range subsets {
+						ds[i] = h.prune(base, level, keep)
+					}
+					return r.testAll(ds)
+				})
+			}
+			h.hoist(level)
+			changed = changed || r.best.Text != before
+		}
+	}
+	return r.best, nil
+}
+
+type hdd struct {
+	*reducer
+	shortest map[string]*schemas.TreeNode // shortest derivation of every symbol, nil if there is none
+}
+
+// units lists the nodes of the level the reduction can prune: the first nodes of the items
+// of repetitions, and the productions longer than their shortest derivation. It reports
+// false if the tree has no node at this level.
+func (h *hdd) units(t *schemas.DerivationTree, level int) ([]*schemas.TreeNode, bool) {
+	res := make([]*schemas.TreeNode, 0)
+	nodes := atLevel(t, level)
+	for _, n := range nodes {
+		if item(n) >= 0 {
+			res = append(res, n)
+		} else if n.Type == schemas.GrammarProduction {
+			if s := h.shortestOf(n.Symbol); s != nil && len(s.Text()) < len(n.Text()) {
+				res = append(res, n)
+			}
+		}
+	}
+	return res, len(nodes) > 0
+}
+
+// atLevel lists the nodes of the tree at depth level, the root being at 0.
+func atLevel(t *schemas.DerivationTree, level int) []*schemas.TreeNode {
+	res := make([]*schemas.TreeNode, 0)
+	var visit func(n *schemas.TreeNode, depth int)
+	visit = func(n *schemas.TreeNode, depth int) {
+		if depth == level {
+			res = append(res, n)
+			return
+		}
+		for _, c := range n.Children {
+			visit(c, depth+1)
+		}
+	}
+	if t.Root != nil {
+		visit(t.Root, 0)
+	}
+	return res
+}
+
+// hoist replaces every production of the level, one after the other, by the first of its
+// descendants of the same production, the shortest first, with which the oracle reports the bug.
+func (h *hdd) hoist(level int) {
+	productions := func(t *schemas.DerivationTree) []*schemas.TreeNode {
+		res := make([]*schemas.TreeNode, 0)
+		for _, n := range atLevel(t, level) {
+			if n.Type == schemas.GrammarProduction {
+				res = append(res, n)
+			}
+		}
+		return res
+	}
+	for i := 0; !h.stopped(); i++ {
+		base := h.best.Derivation
+		nodes := productions(base.Tree())
+		if i >= len(nodes) {
+			return
+		}
+		subs := descendants(nodes[i])
+		order := make([]int, len(subs))
+		for j := range order {
+			order[j] = j
+		}
+		slices.SortStableFunc(order, func(a, b int) int {
+			return len(subs[a].Text()) - len(subs[b].Text())
+		})
+		ds := make([]*schemas.Derivation, len(order))
+		for k, j := range order {
+			t := base.Tree()
+			n := productions(t)[i]
+			sub := descendants(n)[j]
+			sub.Parent = n.Parent
+			if n.Parent == nil {
+				t.Root = sub
+			} else {
+				n.Parent.Children[slices.Index(n.Parent.Children, n)] = sub
+			}
+			if d, err := t.Derivation(); err == nil {
+				ds[k] = d
+			}
+		}
+		h.testAll(ds)
+	}
+}
+
+// descendants lists the descendants of n derived from the same production, in preorder.
+func descendants(n *schemas.TreeNode) []*schemas.TreeNode {
+	res := make([]*schemas.TreeNode, 0)
+	var visit func(c *schemas.TreeNode)
+	visit = func(c *schemas.TreeNode) {
+		if c != n && c.Type == schemas.GrammarProduction && c.Symbol.GetID() == n.Symbol.GetID() {
+			res = append(res, c)
+		}
+		for _, cc := range c.Children {
+			visit(cc)
+		}
+	}
+	visit(n)
+	return res
+}
+
+// item returns the size of the item of a repetition n begins, or -1.
+func item(n *schemas.TreeNode) int {
+	p := n.Parent
+	if p == nil || p.Type&(schemas.GrammarREP|schemas.GrammarPLUS|schemas.GrammarOptional|schemas.GrammarEXT) == 0 {
+		return -1
+	}
+	size := len(p.Symbol.GetSymbols())
+	if size == 0 || slices.Index(p.Children, n)%size != 0 {
+		return -1
+	}
+	return size
+}
+
+func (h *hdd) shortestOf(symbol *schemas.Node) *schemas.TreeNode {
+	if s, ok := h.shortest[symbol.GetID()]; ok {
+		return s
+	}
+	var res *schemas.TreeNode
+	if d, err := symbol.GetGrammar().ShortestDerivation(symbol.GetID()); err == nil {
+		res = d.Tree().Root
+	}
+	h.shortest[symbol.GetID()] = res
+	return res
+}
+
+// prune prunes the units of the level of base but the ones kept, it returns nil if the result is not grammatical.
+func (h *hdd) prune(base *schemas.Derivation, level int, keep []int) *schemas.Derivation {
+	t := base.Tree()
+	units, _ := h.units(t, level)
+	for i, n := range units {
+		if slices.Contains(keep, i) {
+			continue
+		}
+		if size := item(n); size > 0 {
+			p := n.Parent
+			at := slices.Index(p.Children, n)
+			p.Children = slices.Delete(p.Children, at, at+size)
+			continue
+		}
+		s := h.shortestOf(n.Symbol).Clone()
+		s.Parent = n.Parent
+		if n.Parent == nil {
+			t.Root = s
+		} else {
+			n.Parent.Children[slices.Index(n.Parent.Children, n)] = s
+		}
+	}
+	if t.Validate() != nil {
+		return nil
+	}
+	d, err := t.Derivation()
+	if err != nil {
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,215 @@
+// Package reduce strips the parts of a bug-triggering input which are not needed to trigger
+// the bug, keeping the input grammatical: see Reduce, which reduces its derivation tree.
+// ReduceTokens reduces the inputs which cannot be parsed, and ReduceGrammar strips the
+// grammar itself, keeping the part the bug is found with.
+package reduce
+
+import (
+	"context"
+	"errors"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+var ErrNotReproduced = errors.New("the oracle does not report the bug on the input")
+
+// Oracle tells whether the input still triggers the bug.
+type Oracle func(input string) bool
+
+type Options struct {
+	Context  context.Context
+	MaxTests int
+	Render   func(d *schemas.Derivation) string
+	Runner   *Runner
+}
+
+type Option func(*Options)
+
+// WithContext stops the reduction when ctx is done, with the smallest input found so far.
+func WithContext(ctx context.Context) Option {
+	return func(o *Options) {
+		o.Context = ctx
+	}
+}
+
+// WithMaxTests stops the reduction after n calls of the oracle, with the smallest input found so far.
+func WithMaxTests(n int) Option {
+	return func(o *Options) {
+		o.MaxTests = n
+	}
+}
+
+// WithRender sets how the oracle is given a derivation, its GetResult by default.
+func WithRender(render func(d *schemas.Derivation) string) Option {
+	return func(o *Options) {
+		o.Render = render
+	}
+}
+
+// WithRunner tries the candidates of every step of the reduction with the Runner, in
+// parallel and cached, instead of calling the oracle on them one after the other. The
+// oracle of the reduction is then only called on the input reduced, it is usually runner.Test.
+func WithRunner(runner *Runner) Option {
+	return func(o *Options) {
+		o.Runner = runner
+	}
+}
+
+// Result is the smallest input found by a reduction.
+type Result struct {
+	Derivation *schemas.Derivation
+	Text       string
+	Tests      int // calls of the oracle
+}
+
+// reducer keeps the smallest input triggering the bug.
+type reducer struct {
+	oracle  Oracle
+	options Options
+	best    *Result
+}
+
+func newOptions(opts []Option) Options {
+	options := Options{
+		Context: context.Background(),
+		Render: func(d *schemas.Derivation) string {
+			return d.GetResult(nil)
+		},
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func newReducer(oracle Oracle, opts []Option) *reducer {
+	return &reducer{oracle: oracle, options: newOptions(opts)}
+}
+
+// stopped tells whether the reduction must stop.
+func (r *reducer) stopped() bool {
+	return r.options.Context.Err() != nil || r.options.MaxTests > 0 && r.best.Tests >= r.options.MaxTests
+}
+
+// test runs the oracle on the derivation, which becomes the best one if it triggers the bug and is shorter.
+func (r *reducer) test(d *schemas.Derivation) bool {
+	return r.testAll([]*schemas.Derivation{d}) >= 0
+}
+
+// testAll runs the oracle on the derivations which are not nil, see testTexts, and returns
+// the index of the one which became the best, or -1.
+func (r *reducer) testAll(ds []*schemas.Derivation) int {
+	texts := make([]string, 0, len(ds))
+	indices := make([]int, 0, len(ds))
+	for i, d := range ds {
+		if d != nil && !r.stopped() {
+			texts = append(texts, r.options.Render(d))
+			indices = append(indices, i)
+		}
+	}
+	i := r.testTexts(texts)
+	if i < 0 {
+		return -1
+	}
+	r.best.Derivation = ds[indices[i]]
+	return indices[i]
+}
+
+// testTexts runs the oracle on the texts shorter than the best one, and returns the index of
+// the first which triggers the bug, or -1: it becomes the best one. With a Runner, the texts
+// are tried in parallel, and the shortest which triggers the bug is the one returned.
+func (r *reducer) testTexts(texts []string) int {
+	indices := make([]int, 0, len(texts))
+	for i, text := range texts {
+		if len(text) < len(r.best.Text) {
+			indices = append(indices, i)
+		}
+	}
+	if r.options.MaxTests > 0 {
+		indices = indices[:max(min(len(indices), r.options.MaxTests-r.best.Tests), 0)]
+	}
+	if r.stopped() || len(indices) == 0 {
+		return -1
+	}
+	if runner := r.options.Runner; runner != nil {
+		candidates := make([]string, len(indices))
+		for i, j := range indices {
+			candidates[i] = texts[j]
+		}
+		calls, _ := runner.Stats()
+		i := runner.First(r.options.Context, candidates)
+		after, _ := runner.Stats()
+		r.best.Tests += after - calls
+		if i < 0 {
+			return -1
+		}
+		r.best.Text = candidates[i]
+		return indices[i]
+	}
+	for _, i := range indices {
+		if r.stopped() {
+			return -1
+		}
+		r.best.Tests++
+		if r.oracle(texts[i]) {
+			r.best.Text = texts[i]
+			return i
+		}
+	}
+	return -1
+}
+
+// ddmin returns a 1-minimal subset of the n units for which a test holds, as indices,
+// by the delta debugging of Zeller and Hildebrandt. test is given the subsets of a step
+// and returns the index of one for which it holds, or -1. It must hold for all the units.
+func ddmin(n int, test func(subsets [][]int) int) []int {
+	units := make([]int, n)
+	for i := range units {
+		units[i] = i
+	}
+	if test([][]int{{}}) >= 0 {
+		return []int{}
+	}
+	granularity := 2
+	for len(units) >= 2 {
+		chunks := split(units, granularity)
+		reduced := false
+		if i := test(chunks); i >= 0 {
+			units, granularity, reduced = chunks[i], 2, true
+		}
+		if !reduced && granularity > 2 {
+			complements := make([][]int, len(chunks))
+			for i := range chunks {
+				complements[i] = make([]int, 0, len(units)-len(chunks[i]))
+				for j, c := range chunks {
+					if j != i {
+						complements[i] = append(complements[i], c...)
+					}
+				}
+			}
+			if i := test(complements); i >= 0 {
+				units, granularity, reduced = complements[i], max(granularity-1, 2), true
+			}
+		}
+		if reduced {
+			continue
+		}
+		if granularity >= len(units) {
+			break
+		}
+		granularity = min(2*granularity, len(units))
+	}
+	return units
+}
+
+// split cuts the units into n chunks of almost equal sizes.
+func split(units []int, n int) [][]int {
+	res := make([][]int, 0, n)
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + (len(units)-start)/(n-i)
+		res = append(res, units[start:end])
+		start = end
+	}
+	return res
+}
@@ -0,0 +1,115 @@
+package reduce_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/parser"
+	"github.com/CUHK-SE-Group/generic-generator/reduce"
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func parse(t *testing.T) *schemas.Grammar {
+	g, err := parser.Parse("./testdata/list.ebnf", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.MergeProduction()
+	g.BuildShortestNotation()
+	for _, v := range g.GetInternal().GetAllVertices() {
+		if n := g.GetNode(v.GetID()); n.GetType() == schemas.GrammarREP {
+			n.SetRepeatProb(map[int]float64{0: 0.3, 1: 0.3, 2: 0.2, 3: 0.2})
+		}
+	}
+	return g
+}
+
+// generate derives an input of testdata/list.ebnf for which oracle holds.
+func generate(t *testing.T, g *schemas.Grammar, oracle reduce.Oracle) *schemas.Derivation {
+	chain, err := schemas.CreateChain("reduce", &schemas.BacktrackHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.ProbabilityHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(0); ; seed++ {
+		ctx, err := schemas.NewContext(g, "list", context.Background(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Rand = rand.New(rand.NewSource(seed))
+		ctx.Budget = schemas.Budget{MaxTokens: 60}
+		if _, err := schemas.Generate(ctx, chain); err != nil {
+			t.Fatal(err)
+		}
+		if text := ctx.Result.GetResult(nil); len(text) > 20 && oracle(text) {
+			return ctx.Result
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	g := parse(t)
+	for _, c := range []struct {
+		oracle reduce.Oracle
+		want   string
+	}{
+		{func(s string) bool { return strings.Contains(s, "[[") }, "[[]]"},
+		{func(s string) bool { return strings.Count(s, "a") >= 2 }, "[aa]"},
+	} {
+		d := generate(t, g, c.oracle)
+		res, err := reduce.Reduce(d, c.oracle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Text != c.want || res.Derivation.GetResult(nil) != c.want {
+			t.Errorf("reduced %q to %q, want %q", d.GetResult(nil), res.Text, c.want)
+		}
+		if err := res.Derivation.Tree().Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReduceStop(t *testing.T) {
+	g := parse(t)
+	oracle := func(s string) bool { return strings.Contains(s, "[[") }
+	d := generate(t, g, oracle)
+	if _, err := reduce.Reduce(d, func(string) bool { return false }); !errors.Is(err, reduce.ErrNotReproduced) {
+		t.Fatalf("got %v", err)
+	}
+	res, err := reduce.Reduce(d, oracle, reduce.WithMaxTests(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Tests != 3 || !oracle(res.Text) || len(res.Text) > len(d.GetResult(nil)) {
+		t.Errorf("got %q after %d tests", res.Text, res.Tests)
+	}
+}
+
+func TestReduceTokens(t *testing.T) {
+	g := parse(t)
+	input := "[a!b[b?]bb]]"
+	oracle := func(s string) bool {
+		return strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") && strings.Contains(s, "bb")
+	}
+	res, err := reduce.ReduceTokens(g, input, oracle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "[bb]" || res.Derivation == nil || res.Derivation.GetResult(nil) != "[bb]" {
+		t.Fatalf("reduced %q to %q", input, res.Text)
+	}
+	if _, err := reduce.Reduce(res.Derivation, oracle); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = reduce.ReduceTokens(g, input, func(s string) bool { return strings.Contains(s, "?") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "?" || res.Derivation != nil {
+		t.Errorf("reduced %q to %q", input, res.Text)
+	}
+}
@@ -0,0 +1,277 @@
+package reduce
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ContextOracle is an Oracle which gives up once ctx is done, e.g. by killing the target it runs.
+type ContextOracle func(ctx context.Context, input string) bool
+
+// Runner runs an oracle for the reductions, see WithRunner: the candidates of a step are
+// tried in parallel, and the verdicts are cached by the hash of Key and of the candidates, so
+// that no candidate is tried twice, across runs if the cache is on disk. A candidate tried by several
+// callers at once is only tried by the first one, the others wait for its verdict. It is safe
+// for concurrent use, Close releases the cache file.
+type Runner struct {
+	Oracle  ContextOracle
+	Workers int    // candidates tried at once, runtime.NumCPU() by default
+	Cache   string // file the verdicts are appended to, they are only kept in memory if ""
+	Key     string // names the oracle and the bug, a cache file keeps the verdicts of each key apart
+
+	mu       sync.Mutex
+	verdicts map[string]bool    // by hash of the candidate
+	inFlight map[string]*flight // candidates being tried, by hash
+	file     *os.File           // the cache, opened for appending on the first verdict
+	calls    int
+	hits     int
+	err      error // of the cache file
+}
+
+// flight is a call of the oracle other callers of the same candidate wait for.
+type flight struct {
+	done chan struct{}
+}
+
+func (r *Runner) init() {
+	if r.verdicts != nil {
+		return
+	}
+	r.verdicts = make(map[string]bool)
+	r.inFlight = make(map[string]*flight)
+	if r.Cache == "" {
+		return
+	}
+	f, err := os.Open(r.Cache)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		r.err = err
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, verdict, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || verdict != "0" && verdict != "1" {
+			r.err = fmt.Errorf("the cache %s is corrupted: %q", r.Cache, scanner.Text())
+			return
+		}
+		r.verdicts[hash] = verdict == "1"
+	}
+	r.err = scanner.Err()
+}
+
+// hash identifies the input tried by the oracle named key.
+func hash(key, input string) string {
+	sum := sha256.Sum256([]byte(key + "\x00" + input))
+	return hex.EncodeToString(sum[:])
+}
+
+// verdict tells whether the input triggers the bug, from the cache, from the call of the
+// oracle already trying it, or by calling the oracle with ctx. It reports false as second
+// value if the verdict is unknown, since ctx is done.
+func (r *Runner) verdict(ctx context.Context, input string) (bool, bool) {
+	h := hash(r.Key, input)
+	for {
+		r.mu.Lock()
+		r.init()
+		if verdict, ok := r.verdicts[h]; ok {
+			r.hits++
+			r.mu.Unlock()
+			return verdict, true
+		}
+		if ctx.Err() != nil {
+			r.mu.Unlock()
+			return false, false
+		}
+		f, ok := r.inFlight[h]
+		if !ok {
+			f = &flight{done: make(chan struct{})}
+			r.inFlight[h] = f
+			r.mu.Unlock()
+			verdict := r.Oracle(ctx, input)
+			// the verdict of a cancelled call is unknown, a caller waiting for it tries again
+			known := ctx.Err() == nil
+			r.mu.Lock()
+			delete(r.inFlight, h)
+			if known {
+				r.store(h, verdict)
+			}
+			r.mu.Unlock()
+			close(f.done)
+			return verdict, known
+		}
+		r.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return false, false
+		}
+	}
+}
+
+// store caches the verdict of the hash of a candidate, and counts the call of the oracle. r.mu must be held.
+func (r *Runner) store(h string, verdict bool) {
+	r.calls++
+	r.verdicts[h] = verdict
+	if r.Cache == "" || r.err != nil {
+		return
+	}
+	if r.file == nil {
+		if r.file, r.err = os.OpenFile(r.Cache, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); r.err != nil {
+			return
+		}
+	}
+	line := h + " 0\n"
+	if verdict {
+		line = h + " 1\n"
+	}
+	if _, err := r.file.WriteString(line); err != nil {
+		r.err = err
+	}
+}
+
+// Close closes the cache file, it is opened again if a verdict is cached afterwards.
+func (r *Runner) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Stats returns the number of calls of the oracle, and of verdicts found in the cache.
+func (r *Runner) Stats() (calls, hits int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls, r.hits
+}
+
+// Err returns the first error met reading or writing the cache file, the verdicts are only kept in memory since.
+func (r *Runner) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Test tells whether the input triggers the bug, it is an Oracle.
+func (r *Runner) Test(input string) bool {
+	verdict, _ := r.verdict(context.Background(), input)
+	return verdict
+}
+
+func (r *Runner) workers() int {
+	if r.Workers > 0 {
+		return r.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// Run tells for every input whether it triggers the bug, trying Workers of them at once.
+// The inputs not tried once ctx is done are reported false.
+func (r *Runner) Run(ctx context.Context, inputs []string) []bool {
+	res := make([]bool, len(inputs))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < r.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res[i], _ = r.verdict(ctx, inputs[i])
+			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return res
+}
+
+// First returns the index of the shortest input which triggers the bug, the first one of
+// this length, or -1. The inputs are tried the shortest first, Workers of them at once: once
+// one triggers the bug, the ones in flight it precedes are cancelled, and the others skipped.
+func (r *Runner) First(ctx context.Context, inputs []string) int {
+	// precedes orders the inputs by length, then by index
+	precedes := func(i, j int) bool {
+		return len(inputs[i]) < len(inputs[j]) || len(inputs[i]) == len(inputs[j]) && i < j
+	}
+	order := make([]int, len(inputs))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		if precedes(a, b) {
+			return -1
+		}
+		return 1
+	})
+
+	mu := sync.Mutex{}
+	best := -1
+	inFlight := make(map[int]context.CancelFunc)
+	found := func(i int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if best >= 0 && precedes(best, i) {
+			return
+		}
+		best = i
+		for j, cancel := range inFlight {
+			if precedes(best, j) {
+				cancel()
+			}
+		}
+	}
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < r.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mu.Lock()
+				skip := best >= 0 && precedes(best, i) || ctx.Err() != nil
+				mu.Unlock()
+				if skip {
+					continue
+				}
+				c, cancel := context.WithCancel(ctx)
+				mu.Lock()
+				inFlight[i] = cancel
+				mu.Unlock()
+				verdict, known := r.verdict(c, inputs[i])
+				mu.Lock()
+				delete(inFlight, i)
+				mu.Unlock()
+				if known && verdict {
+					found(i)
+				}
+				cancel()
+			}
+		}()
+	}
+	for _, i := range order {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return best
+}
@@ -0,0 +1,132 @@
+package reduce_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CUHK-SE-Group/generic-generator/reduce"
+)
+
+func TestRunnerFirst(t *testing.T) {
+	var cancelled atomic.Bool
+	runner := &reduce.Runner{
+		Workers: 4,
+		Oracle: func(ctx context.Context, input string) bool {
+			if input == "aaaa" {
+				// a slow target, stopped once a shorter input is found
+				select {
+				case <-ctx.Done():
+					cancelled.Store(true)
+				case <-time.After(10 * time.Second):
+				}
+				return true
+			}
+			time.Sleep(50 * time.Millisecond)
+			return input == "cc"
+		},
+	}
+	start := time.Now()
+	if i := runner.First(context.Background(), []string{"aaaa", "cc", "d"}); i != 1 {
+		t.Errorf("got %d", i)
+	}
+	if time.Since(start) > 5*time.Second || !cancelled.Load() {
+		t.Error("the slow input was not cancelled")
+	}
+	// the verdict of the cancelled input is unknown
+	if calls, _ := runner.Stats(); calls != 2 {
+		t.Errorf("got %d calls", calls)
+	}
+}
+
+func TestRunnerCache(t *testing.T) {
+	g := parse(t)
+	oracle := func(s string) bool { return strings.Contains(s, "[[") }
+	d := generate(t, g, oracle)
+	var calls atomic.Int32
+	cache := filepath.Join(t.TempDir(), "verdicts")
+	newRunner := func(key string) *reduce.Runner {
+		return &reduce.Runner{
+			Cache: cache,
+			Key:   key,
+			Oracle: func(ctx context.Context, input string) bool {
+				calls.Add(1)
+				return oracle(input)
+			},
+		}
+	}
+	runner := newRunner("[[")
+	t.Cleanup(func() { runner.Close() })
+	res, err := reduce.Reduce(d, runner.Test, reduce.WithRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "[[]]" || runner.Err() != nil {
+		t.Fatalf("reduced %q to %q: %v", d.GetResult(nil), res.Text, runner.Err())
+	}
+	first := calls.Load()
+	if int(first) != res.Tests {
+		t.Errorf("the oracle was called %d times, %d reported", first, res.Tests)
+	}
+
+	// a new run reads the verdicts back from the disk
+	if err := runner.Close(); err != nil {
+		t.Fatal(err)
+	}
+	runner = newRunner("[[")
+	res, err = reduce.Reduce(d, runner.Test, reduce.WithRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "[[]]" || calls.Load() != first || res.Tests != 1 {
+		t.Errorf("reduced to %q after %d more calls", res.Text, calls.Load()-first)
+	}
+	if _, hits := runner.Stats(); hits == 0 {
+		t.Error("no verdict found in the cache")
+	}
+
+	// the verdicts of another bug are not taken from the same file
+	if err := runner.Close(); err != nil {
+		t.Fatal(err)
+	}
+	runner = newRunner("another bug")
+	if !runner.Test(d.GetResult(nil)) {
+		t.Error("got false")
+	}
+	if calls, hits := runner.Stats(); calls != 1 || hits != 0 {
+		t.Errorf("got %d calls and %d hits", calls, hits)
+	}
+}
+
+func TestRunnerInFlight(t *testing.T) {
+	var calls atomic.Int32
+	runner := &reduce.Runner{
+		Oracle: func(ctx context.Context, input string) bool {
+			calls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			return true
+		},
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !runner.Test("x") {
+				t.Error("got false")
+			}
+		}()
+	}
+	wg.Wait()
+	// the callers of a candidate being tried wait for its verdict
+	if calls.Load() != 1 {
+		t.Errorf("the oracle was called %d times", calls.Load())
+	}
+	if calls, hits := runner.Stats(); calls != 1 || hits != 7 {
+		t.Errorf("got %d calls and %d hits", calls, hits)
+	}
+}
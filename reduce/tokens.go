@@ -0,0 +1,44 @@
+package reduce
+
+import (
+	"strings"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// ReduceTokens reduces an input which may not be derived from the grammar, e.g. found by
+// another tool: the delta debugging removes its tokens, see Grammar.Tokenize, then its
+// characters, as long as the oracle reports the bug. The Derivation of the result is the
+// parse of its text by the grammar, for Reduce to go on with, or nil if it cannot be parsed.
+func ReduceTokens(g *schemas.Grammar, input string, oracle Oracle, opts ...Option) (*Result, error) {
+	r := newReducer(oracle, opts)
+	r.best = &Result{Text: input, Tests: 1}
+	if !oracle(input) {
+		return nil, ErrNotReproduced
+	}
+	tokens := g.Tokenize(input)
+	ddmin(len(tokens), func(subsets [][]int) int {
+		return r.testTexts(joinAll(tokens, subsets))
+	})
+	chars := strings.Split(r.best.Text, "")
+	ddmin(len(chars), func(subsets [][]int) int {
+		return r.testTexts(joinAll(chars, subsets))
+	})
+	if d, err := g.Parse(g.GetStartSym(), r.best.Text); err == nil {
+		r.best.Derivation = d
+	}
+	return r.best, nil
+}
+
+// joinAll concatenates the units kept by every subset, given as indices.
+func joinAll(units []string, subsets [][]int) []string {
+	res := make([]string, len(subsets))
+	for i, keep := range subsets {
+		b := strings.Builder{}
+		for _, j := range keep {
+			b.WriteString(units[j])
+		}
+		res[i] = b.String()
+	}
+	return res
+}
@@ -0,0 +1,162 @@
+package schemas
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+const BacktrackHandlerName = "backtrack_handler"
+
+// BacktrackHandler drives the generation like the other drivers: placed first in
+// the chain, it expands the top of the symbol stack with the rest of the chain.
+// Before every choice (OR, REP, PLUS, Optional, EXT) it takes a checkpoint; when
+// the generation later hits a dead end, an unsatisfiable constraint or the budget,
+// it rolls back to the latest choice with an alternative left and takes it.
+type BacktrackHandler struct {
+	MaxBacktracks int // rollbacks allowed in one generation, 0 means no limit
+	MaxRepeat     int // largest repetition count tried for REP and PLUS, 3 by default
+	MaxChoices    int // choices kept for backtracking, the oldest are dropped first; 0 means no limit
+}
+
+// choicePoint is a choice made during the generation, with what to restore to take another alternative.
+type choicePoint struct {
+	seq   int
+	cp    *Checkpoint
+	node  *Node
+	tried map[string]bool
+}
+
+const choiceTypes = GrammarOR | GrammarREP | GrammarPLUS | GrammarOptional | GrammarEXT
+
+func (h *BacktrackHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
+	ctx.CurrentNode = ctx.SymbolStack.Top()
+	ctx.ResultBuffer = make([]*Node, 0)
+	var cp *Checkpoint
+	if ctx.CurrentNode.GetType()&choiceTypes != 0 {
+		cp = ctx.Checkpoint()
+	}
+
+	chain.Next(ctx, cb)
+	if cp != nil {
+		// a choice that failed by itself can still be retried with another alternative
+		p := &choicePoint{cp: cp, node: ctx.CurrentNode, tried: make(map[string]bool)}
+		if ctx.Error == nil {
+			p.tried[signature(ctx.ResultBuffer)] = true
+		}
+		h.record(ctx, p)
+	}
+	if ctx.Error == nil {
+		expand(ctx, ctx.ResultBuffer)
+		if ctx.overBudget() {
+			ctx.Error = ErrBudgetExceeded
+		}
+	}
+	if ctx.Error != nil && retryable(ctx.Error) {
+		h.backtrack(ctx)
+	}
+}
+
+func (h *BacktrackHandler) record(ctx *Context, p *choicePoint) {
+	p.seq = ctx.choiceSeq
+	ctx.choiceSeq++
+	ctx.choices = append(ctx.choices, p)
+	if h.MaxChoices > 0 && len(ctx.choices) > h.MaxChoices {
+		ctx.choices = ctx.choices[len(ctx.choices)-h.MaxChoices:]
+	}
+}
+
+// backtrack rolls back to the latest choice with an untried alternative and takes it.
+// If there is none, or the budget of rollbacks is spent, the error is left as is.
+func (h *BacktrackHandler) backtrack(ctx *Context) {
+	for len(ctx.choices) > 0 {
+		if h.MaxBacktracks > 0 && ctx.backtracks >= h.MaxBacktracks {
+			return
+		}
+		p := ctx.choices[len(ctx.choices)-1]
+		alternatives := h.alternatives(p.node)
+		untried := make([][]*Node, 0, len(alternatives))
+		for _, alt := range alternatives {
+			if !p.tried[signature(alt)] {
+				untried = append(untried, alt)
+			}
+		}
+		if len(untried) == 0 {
+			ctx.choices = ctx.choices[:len(ctx.choices)-1]
+			continue
+		}
+		ctx.backtracks++
+		choices := ctx.choices
+		ctx.Rollback(p.cp)
+		ctx.choices = choices
+		alt := untried[ctx.Rand.Intn(len(untried))]
+		p.tried[signature(alt)] = true
+		ctx.CurrentNode = ctx.SymbolStack.Top()
+		if ctx.recording {
+			ctx.recordChoice(p.node, alt)
+		}
+		expand(ctx, alt)
+		if !ctx.overBudget() {
+			return
+		}
+	}
+}
+
+// alternatives lists the possible expansions of a choice node, children in source order.
+func (h *BacktrackHandler) alternatives(n *Node) [][]*Node {
+	children := sourceOrder(n.GetSymbols())
+	maxRepeat := h.MaxRepeat
+	if maxRepeat <= 0 {
+		maxRepeat = 3
+	}
+	res := make([][]*Node, 0)
+	switch n.GetType() {
+	case GrammarOR:
+		for _, c := range children {
+			res = append(res, []*Node{c})
+		}
+	case GrammarOptional, GrammarEXT:
+		res = append(res, []*Node{}, children)
+	case GrammarREP, GrammarPLUS:
+		least := 0
+		if n.GetType() == GrammarPLUS {
+			least = 1
+		}
+		for k := least; k <= maxRepeat; k++ {
+			res = append(res, repeatSymbols(children, k))
+		}
+	}
+	return res
+}
+
+// expand replaces the top of the symbol stack by its expansion and records it in the derivation.
+func expand(ctx *Context, expansion []*Node) {
+	ctx.SymbolStack.Pop()
+	if len(expansion) != 0 {
+		ctx.SymbolStack.Push(expansion...)
+	}
+	for i := len(expansion) - 1; i >= 0; i-- {
+		ctx.Result.AddNode(expansion[i])
+		ctx.Result.AddEdge(ctx.CurrentNode, expansion[i])
+	}
+}
+
+func signature(expansion []*Node) string {
+	ids := make([]string, len(expansion))
+	for i, n := range expansion {
+		ids[i] = n.GetID()
+	}
+	return strings.Join(ids, ",")
+}
+
+func (h *BacktrackHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *BacktrackHandler) Name() string {
+	return BacktrackHandlerName
+}
+
+func (h *BacktrackHandler) Type() GrammarType {
+	return math.MaxInt
+}
@@ -0,0 +1,117 @@
+package schemas_test
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// createDeadEndGrammar builds P = 'x', Q; Q = 'y' | R, where R is not defined.
+func createDeadEndGrammar() *schemas.Grammar {
+	g := createTwoProductionGrammar()
+	g.GetNode("Q#0").AddSymbol(schemas.NewNode(g, schemas.GrammarID, "Q#3", "R"))
+	return g
+}
+
+// tokensHandler accepts the terminal only when exactly Want terminals were generated before it.
+type tokensHandler struct {
+	Want int
+}
+
+func (h *tokensHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	if got := ctx.SymbolStack.Tokens(); got != h.Want {
+		ctx.Error = fmt.Errorf("%w: %d tokens before", schemas.ErrConstraintUnsatisfiable, got)
+		return
+	}
+	chain.Next(ctx, cb)
+}
+
+func (h *tokensHandler) HookRoute() []regexp.Regexp {
+	return []regexp.Regexp{*regexp.MustCompile("^U#1$")}
+}
+
+func (h *tokensHandler) Name() string {
+	return "tokens"
+}
+
+func (h *tokensHandler) Type() schemas.GrammarType {
+	return schemas.GrammarTerminal
+}
+
+func TestBacktrackHandler(t *testing.T) {
+	g := createDeadEndGrammar()
+	chain, err := schemas.CreateChain("backtrack", &schemas.BacktrackHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.OrHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		ctx := newContext(t, g)
+		if _, err := schemas.Generate(ctx, chain); err != nil {
+			t.Fatal(err)
+		}
+		if got := ctx.Result.GetResult(nil); got != "xy" && got != "xz" {
+			t.Errorf("generated %q", got)
+		}
+		checkNoOrphans(t, ctx.Result)
+	}
+}
+
+func TestBacktrackHandlerExhausted(t *testing.T) {
+	g := createDeadEndGrammar()
+	g.GetNode("Q#1").SetContent("S")
+	g.GetNode("Q#1").SetType(schemas.GrammarID)
+	g.GetNode("Q#2").SetContent("S")
+	g.GetNode("Q#2").SetType(schemas.GrammarID)
+	for _, h := range []*schemas.BacktrackHandler{{}, {MaxBacktracks: 1}} {
+		chain, err := schemas.CreateChain("backtrack", h, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.OrHandler{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := newContext(t, g)
+		if _, err := schemas.Generate(ctx, chain); !errors.Is(err, schemas.ErrDeadEnd) {
+			t.Errorf("got error %v, want a dead end once every alternative failed", err)
+		}
+	}
+}
+
+func TestBacktrackRepetition(t *testing.T) {
+	// U = 'c', {'b'}; 'c' is generated last and requires exactly two 'b'
+	g := schemas.NewGrammar(schemas.WithStartSym("U"))
+	u := schemas.NewNode(g, schemas.GrammarProduction, "U", "'c', {'b'}")
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "U#0", "'c', {'b'}")
+	rep := schemas.NewNode(g, schemas.GrammarREP, "U#2", "{'b'}")
+	u.AddSymbol(cat)
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "U#1", "'c'"))
+	cat.AddSymbol(rep)
+	rep.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "U#3", "'b'"))
+
+	chain, err := schemas.CreateChain("backtrack", &schemas.BacktrackHandler{}, &schemas.CatHandler{}, &schemas.RepHandler{}, &tokensHandler{Want: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		ctx := newContext(t, g)
+		if _, err := schemas.Generate(ctx, chain); err != nil {
+			t.Fatal(err)
+		}
+		if got := ctx.Result.GetResult(nil); got != "cbb" {
+			t.Errorf("generated %q, want cbb", got)
+		}
+		checkNoOrphans(t, ctx.Result)
+	}
+}
+
+// checkNoOrphans checks that the rollbacks removed the derived nodes of the abandoned choices:
+// every node but the root is reached by an edge.
+func checkNoOrphans(t *testing.T, d *schemas.Derivation) {
+	t.Helper()
+	g := d.GetInternal()
+	for _, v := range g.GetAllVertices() {
+		if v.GetID() != d.GetStartSym()+"#0" && len(g.GetInEdges(v)) == 0 {
+			t.Errorf("derived node %s is left without edges", v.GetID())
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package schemas
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Budget bounds a single generation. A zero field means no limit.
+//
+// Before every choice the handlers check that the shortest completion of
+// everything still pending fits into what is left of the budget; once it no
+// longer does, they take the shortest completion path (the children with the
+// smallest DistanceToTerminal, no repetition, no optional part), so every run
+// finishes within the budget. It relies on Grammar.BuildShortestNotation.
+type Budget struct {
+	MaxDepth      int // depth of the derivation tree
+	MaxTokens     int // number of terminals generated
+	MaxExpansions int // number of symbols popped from the symbol stack
+	MaxRecursion  int // nesting of a production inside itself
+}
+
+// completion is the cost of the shortest completion of a node.
+type completion struct {
+	expansions int
+	tokens     int
+	height     int
+}
+
+// shortestSymbol returns the child with the smallest DistanceToTerminal.
+func shortestSymbol(n *Node) *Node {
+	var best *Node
+	for _, c := range n.GetSymbols() {
+		if best == nil || c.GetDistance() < best.GetDistance() {
+			best = c
+		}
+	}
+	return best
+}
+
+// repeatSymbols returns the symbols repeated cnt times.
+func repeatSymbols(symbols []*Node, cnt int) []*Node {
+	res := make([]*Node, 0, len(symbols)*cnt)
+	for i := 0; i < cnt; i++ {
+		for _, s := range symbols {
+			// every occurrence is a node of its own on the stack
+			res = append(res, &Node{internal: s.internal})
+		}
+	}
+	return res
+}
+
+// completionOf returns the cost of completing n through its shortest completion path.
+func (c *Context) completionOf(n *Node) completion {
+	if c.completions == nil {
+		c.completions = make(map[string]completion)
+	}
+	if res, ok := c.completions[n.GetID()]; ok {
+		return res
+	}
+	// guard against cycles, which only happen if BuildShortestNotation was not called
+	c.completions[n.GetID()] = completion{expansions: 1}
+
+	res := completion{expansions: 1}
+	add := func(children ...*Node) {
+		for _, child := range children {
+			if child == nil {
+				continue
+			}
+			cc := c.completionOf(child)
+			res.expansions += cc.expansions
+			res.tokens += cc.tokens
+			res.height = max(res.height, cc.height+1)
+		}
+	}
+	switch n.GetType() {
+	case GrammarTerminal:
+		res.tokens = 1
+	case GrammarOR:
+		add(shortestSymbol(n))
+	case GrammarREP, GrammarOptional, GrammarEXT, GrammarSUB:
+	case GrammarID:
+		add(c.Grammar.GetNode(n.GetContent()))
+	default:
+		add(n.GetSymbols()...)
+	}
+	c.completions[n.GetID()] = res
+	return res
+}
+
+// reserve returns the cost of the shortest completion of everything on the symbol stack.
+// It is summed up once, then kept up to date by the stack on every push and pop.
+func (c *Context) reserve() completion {
+	if !c.reserving {
+		c.reserved = completion{}
+		for _, n := range c.SymbolStack.GetStack() {
+			c.reserveNode(n, 1)
+		}
+		c.reserving = true
+	}
+	return c.reserved
+}
+
+// reserveNode adds the shortest completion of a node pushed, sign 1, or popped, sign -1, to the reserve.
+func (c *Context) reserveNode(n *Node, sign int) {
+	if n == nil {
+		return
+	}
+	cc := c.completionOf(n)
+	c.reserved.expansions += sign * cc.expansions
+	c.reserved.tokens += sign * cc.tokens
+}
+
+func (b Budget) unlimited() bool {
+	return b.MaxDepth == 0 && b.MaxTokens == 0 && b.MaxExpansions == 0 && b.MaxRecursion == 0
+}
+
+func within(used, limit int) bool {
+	return limit == 0 || used <= limit
+}
+
+// overBudget reports whether the generation already went beyond the budget,
+// which only happens when even the shortest completion does not fit.
+func (c *Context) overBudget() bool {
+	if c.Budget.unlimited() {
+		return false
+	}
+	return !within(c.SymbolStack.Expansions(), c.Budget.MaxExpansions) ||
+		!within(c.SymbolStack.Tokens(), c.Budget.MaxTokens) ||
+		!within(c.SymbolStack.Depth(), c.Budget.MaxDepth)
+}
+
+// Shrinking reports whether the handlers should take the shortest completion path
+// for the current node, either because ShrinkMode is set or because the budget
+// leaves no room for anything else.
+func (c *Context) Shrinking() bool {
+	if c.Mode == ShrinkMode {
+		return true
+	}
+	if c.Budget.unlimited() || c.SymbolStack.Top() == nil {
+		return false
+	}
+	if c.Budget.MaxRecursion != 0 && c.SymbolStack.Recursion() >= c.Budget.MaxRecursion {
+		return true
+	}
+	// no slack left: anything longer than the shortest completion would exceed the budget
+	cur := c.completionOf(c.SymbolStack.Top())
+	return !c.fits(completion{expansions: cur.expansions + 1, tokens: cur.tokens + 1}, cur.height+1)
+}
+
+// Fits reports whether expanding the current node into children keeps the
+// generation within the budget, assuming the shortest completion afterwards.
+func (c *Context) Fits(children ...*Node) bool {
+	if c.Budget.unlimited() || c.SymbolStack.Top() == nil {
+		return true
+	}
+	expanded := completion{expansions: 1}
+	height := 0
+	for _, child := range children {
+		cc := c.completionOf(child)
+		expanded.expansions += cc.expansions
+		expanded.tokens += cc.tokens
+		height = max(height, cc.height+1)
+	}
+	return c.fits(expanded, height)
+}
+
+// fits checks the budget when the top of the stack is completed at the given cost.
+func (c *Context) fits(top completion, height int) bool {
+	cur := c.completionOf(c.SymbolStack.Top())
+	reserve := c.reserve()
+	return within(c.SymbolStack.Expansions()+reserve.expansions-cur.expansions+top.expansions, c.Budget.MaxExpansions) &&
+		within(c.SymbolStack.Tokens()+reserve.tokens-cur.tokens+top.tokens, c.Budget.MaxTokens) &&
+		within(c.SymbolStack.Depth()+height, c.Budget.MaxDepth)
+}
+
+// sizeLeft returns how many terminals the current node may yield within the budget, once the
+// other pending nodes take their shortest completion, or -1 if the number is not limited.
+// Every terminal is an expansion too, so MaxExpansions bounds it as well as MaxTokens.
+func (c *Context) sizeLeft() int {
+	if c.Budget.MaxTokens == 0 && c.Budget.MaxExpansions == 0 || c.SymbolStack.Top() == nil {
+		return -1
+	}
+	cur := c.completionOf(c.SymbolStack.Top())
+	reserve := c.reserve()
+	res := -1
+	if c.Budget.MaxTokens != 0 {
+		res = c.Budget.MaxTokens - c.SymbolStack.Tokens() - (reserve.tokens - cur.tokens)
+	}
+	if c.Budget.MaxExpansions != 0 {
+		// the nonterminals of the shortest completion of the current node are expanded whatever its size
+		left := c.Budget.MaxExpansions - c.SymbolStack.Expansions() - (reserve.expansions - cur.expansions) - (cur.expansions - cur.tokens)
+		if res < 0 || left < res {
+			res = left
+		}
+	}
+	return max(res, 0)
+}
+
+// fitting returns the alternatives of the current node that fit into the budget.
+func (c *Context) fitting(alternatives []*Node) []*Node {
+	res := make([]*Node, 0, len(alternatives))
+	for _, n := range alternatives {
+		if c.Fits(n) {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// ShortestDerivation derives the symbol along its shortest completion path, like the
+// handlers do once shrinking: the children with the smallest DistanceToTerminal, no
+// repetition and no optional part. It relies on Grammar.MergeProduction and
+// Grammar.BuildShortestNotation, without them a recursive production fails with ErrDeadEnd.
+func (g *Grammar) ShortestDerivation(symbol string) (*Derivation, error) {
+	n := g.GetNode(symbol)
+	if n == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSymbolNotFound, symbol)
+	}
+	var shortest func(n *Node, path []string) (*TreeNode, error)
+	shortest = func(n *Node, path []string) (*TreeNode, error) {
+		res := &TreeNode{ID: n.GetID(), Symbol: n, Type: n.GetType(), Content: n.GetContent()}
+		symbols := sourceOrder(n.GetSymbols())
+		children := make([]*Node, 0)
+		switch n.GetType() {
+		case GrammarTerminal:
+			res.Leaf = true
+			res.Value = renderTerminal(n.GetContent())
+		case GrammarProduction:
+			if slices.Contains(path, n.GetID()) {
+				return nil, fmt.Errorf("%w: %s is on its own shortest completion path", ErrDeadEnd, n.GetID())
+			}
+			path = append(path, n.GetID())
+			children = symbols
+		case GrammarID:
+			prod := g.GetNode(n.GetContent())
+			if prod == nil {
+				return nil, fmt.Errorf("%w: the identifier %s does not exist", ErrDeadEnd, n.GetContent())
+			}
+			children = append(children, prod)
+		case GrammarOR:
+			if len(symbols) > 0 {
+				children = append(children, shortestSymbol(n))
+			}
+		case GrammarREP, GrammarOptional, GrammarEXT:
+		case GrammarSUB:
+			children = symbols[:min(1, len(symbols))]
+		default:
+			children = symbols
+		}
+		for _, c := range children {
+			child, err := shortest(c, path)
+			if err != nil {
+				return nil, err
+			}
+			child.Parent = res
+			res.Children = append(res.Children, child)
+		}
+		return res, nil
+	}
+	root, err := shortest(n, nil)
+	if err != nil {
+		return nil, err
+	}
+	return (&DerivationTree{Root: root}).Derivation()
+}
@@ -0,0 +1,151 @@
+package schemas_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func TestBudget(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	g.BuildShortestNotation()
+	chain, err := schemas.CreateChain("budget", &driveHandler{routes: []schemas.Handler{
+		&schemas.CatHandler{}, &schemas.OrHandler{}, &schemas.IDHandler{},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	budgets := []schemas.Budget{
+		{MaxTokens: 5},
+		{MaxExpansions: 30},
+		{MaxDepth: 8},
+		{MaxRecursion: 3},
+	}
+	for _, b := range budgets {
+		for i := 0; i < 100; i++ {
+			ctx, err := schemas.NewContext(g, "S", context.Background(), nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx.Budget = b
+			depth, recursion := 0, 0
+			for !ctx.GetFinish() {
+				depth = max(depth, ctx.SymbolStack.Depth())
+				recursion = max(recursion, ctx.SymbolStack.Recursion())
+				chain.Next(ctx, func(result *schemas.Result) {
+					ctx = result.GetCtx()
+				})
+				ctx.HandlerIndex = 0
+			}
+			if b.MaxTokens != 0 && ctx.SymbolStack.Tokens() > b.MaxTokens {
+				t.Errorf("%+v: generated %d tokens", b, ctx.SymbolStack.Tokens())
+			}
+			if b.MaxExpansions != 0 && ctx.SymbolStack.Expansions() > b.MaxExpansions {
+				t.Errorf("%+v: expanded %d symbols", b, ctx.SymbolStack.Expansions())
+			}
+			if b.MaxDepth != 0 && depth > b.MaxDepth {
+				t.Errorf("%+v: reached depth %d", b, depth)
+			}
+			if b.MaxRecursion != 0 && recursion > b.MaxRecursion {
+				t.Errorf("%+v: nested S %d times", b, recursion)
+			}
+			if got := countTerminals(ctx); got != ctx.SymbolStack.Tokens() {
+				t.Errorf("counted %d tokens, the trace holds %d terminals", ctx.SymbolStack.Tokens(), got)
+			}
+		}
+	}
+}
+
+// createRepetitionGrammar builds U = {'a'}, ('b')+, ['SP']; BracketHandler expands the optional part only if it holds SP.
+func createRepetitionGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("U"))
+	u := schemas.NewNode(g, schemas.GrammarProduction, "U", "{'a'}, ('b')+, ['SP']")
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "U#0", "{'a'}, ('b')+, ['SP']")
+	rep := schemas.NewNode(g, schemas.GrammarREP, "U#1", "{'a'}")
+	plus := schemas.NewNode(g, schemas.GrammarPLUS, "U#3", "('b')+")
+	opt := schemas.NewNode(g, schemas.GrammarOptional, "U#5", "['SP']")
+	u.AddSymbol(cat)
+	cat.AddSymbol(rep)
+	cat.AddSymbol(plus)
+	cat.AddSymbol(opt)
+	rep.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "U#2", "'a'"))
+	plus.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "U#4", "'b'"))
+	opt.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "U#6", "'SP'"))
+	return g
+}
+
+func TestBudgetRepetition(t *testing.T) {
+	g := createRepetitionGrammar()
+	g.BuildShortestNotation()
+	chains := make([]*schemas.Chain, 0)
+	for _, routes := range [][]schemas.Handler{
+		{&schemas.CatHandler{}, &schemas.RepHandler{Probability: 0.9}, &schemas.PlusHandler{}, &schemas.BracketHandler{}},
+		{&schemas.CatHandler{}, &schemas.ProbabilityHandler{}, &schemas.BracketHandler{}},
+	} {
+		chain, err := schemas.CreateChain("budget", &driveHandler{routes: routes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chains = append(chains, chain)
+	}
+	chain, err := schemas.CreateChain("uniform", &driveHandler{}, &schemas.UniformSizeHandler{MinSize: 1, MaxSize: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chains = append(chains, chain)
+
+	for _, b := range []schemas.Budget{{MaxTokens: 3}, {MaxTokens: 6}} {
+		for _, chain := range chains {
+			for i := 0; i < 100; i++ {
+				ctx, err := schemas.NewContext(g, "U", context.Background(), nil, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ctx.Budget = b
+				if _, err := schemas.Generate(ctx, chain); err != nil {
+					t.Fatalf("%s, %+v: %v", chain.Name, b, err)
+				}
+				if got := ctx.SymbolStack.Tokens(); got > b.MaxTokens {
+					t.Errorf("%s, %+v: generated %d tokens", chain.Name, b, got)
+				}
+			}
+		}
+	}
+	// the expansions are not bounded by the sizes, Generate retries the trees beyond them
+	b := schemas.Budget{MaxExpansions: 12}
+	for i := 0; i < 100; i++ {
+		ctx, err := schemas.NewContext(g, "U", context.Background(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Budget = b
+		if _, err := schemas.Generate(ctx, chain, schemas.WithRetry(50)); err != nil {
+			t.Fatalf("%+v: %v", b, err)
+		}
+		if got := ctx.SymbolStack.Expansions(); got > b.MaxExpansions {
+			t.Errorf("%+v: expanded %d symbols", b, got)
+		}
+	}
+}
+
+func TestShrinking(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	g.BuildShortestNotation()
+	ctx, err := schemas.NewContext(g, "S", context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Shrinking() {
+		t.Error("an unlimited budget should not shrink")
+	}
+	ctx.Budget = schemas.Budget{MaxTokens: 1}
+	if !ctx.Shrinking() {
+		t.Error("a budget of a single token leaves no room")
+	}
+	ctx.Mode = schemas.ShrinkMode
+	ctx.Budget = schemas.Budget{}
+	if !ctx.Shrinking() {
+		t.Error("ShrinkMode should always shrink")
+	}
+}
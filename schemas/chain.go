@@ -1,19 +1,80 @@
 package schemas
 
-import "log/slog"
+import (
+	"log/slog"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sync/atomic"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas/query"
+)
 
 type Chain struct {
 	Name     string
 	Handlers []Handler
+
+	routing atomic.Pointer[routing] // compiled routes of Handlers, see routeOf
+}
+
+// routing is the compiled routes of the handlers of a chain, routes[i] is the one of handlers[i].
+type routing struct {
+	handlers []Handler
+	routes   []*route
+}
+
+// PathRouter is implemented by handlers scoped by PathQuery expressions, e.g. "program//where_clause",
+// matched against the production path from the root of the derivation tree to the current node.
+type PathRouter interface {
+	PathRoute() []string
+}
+
+// route restricts a handler to some nodes. A handler without any route handles every node of its type.
+type route struct {
+	ids   []regexp.Regexp
+	paths []query.Pattern
+}
+
+func newRoute(h Handler) *route {
+	r := &route{ids: h.HookRoute()}
+	if pr, ok := h.(PathRouter); ok {
+		for _, p := range pr.PathRoute() {
+			r.paths = append(r.paths, query.Compile(p))
+		}
+	}
+	return r
+}
+
+// match reports whether the node on the top of the stack matches the route, by its ID or by its production path.
+func (r *route) match(ctx *Context) bool {
+	if len(r.ids) == 0 && len(r.paths) == 0 {
+		return true
+	}
+	id := ctx.SymbolStack.Top().GetID()
+	for i := range r.ids {
+		if r.ids[i].MatchString(id) {
+			return true
+		}
+	}
+	if len(r.paths) == 0 {
+		return false
+	}
+	path := ctx.SymbolStack.Path()
+	for _, p := range r.paths {
+		if p.Match(path) {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Chain) Clone() Chain {
-	var clone = Chain{
+	// the routes of the clone are compiled again on its first node
+	return Chain{
 		Name:     c.Name,
-		Handlers: make([]Handler, len(c.Handlers)),
+		Handlers: slices.Clone(c.Handlers),
 	}
-	copy(clone.Handlers, c.Handlers)
-	return clone
 }
 
 // AddHandler chain can add a handler
@@ -21,30 +82,79 @@ func (c *Chain) AddHandler(h Handler) {
 	c.Handlers = append(c.Handlers, h)
 }
 
-// Next is for to handle next handler in the chain
+// Next is for to handle next handler in the chain.
+// Handlers that do not accept the type of the current node or whose routes do not match it are skipped.
+// Once Context.Error is set the generation finishes and the error is surfaced through the Result.
 func (c *Chain) Next(ctx *Context, f ResponseCallBack) {
-	index := ctx.HandlerIndex
+	if ctx.Error == nil && ctx.overBudget() {
+		ctx.Error = ErrBudgetExceeded
+	}
+	if ctx.Error != nil {
+		ctx.finish = true
+		f(NewResult(ctx))
+		return
+	}
 	if ctx.SymbolStack.Top() == nil || ctx.SymbolStack.Empty() {
 		if ctx.finish {
 			slog.Error("Warning: Symbol queue should not be empty")
 		}
+		ctx.SymbolStack.settle()
 		ctx.finish = true
 		r := NewResult(ctx)
 		f(r)
 		return
 	}
-	if index >= len(c.Handlers) {
-		return
+	for ctx.HandlerIndex < len(c.Handlers) {
+		index := ctx.HandlerIndex
+		ctx.HandlerIndex++
+		if ctx.SymbolStack.Top().GetType()&c.Handlers[index].Type() != 0 && c.satisfy(ctx, index) {
+			c.Handlers[index].Handle(c, ctx, f)
+			return
+		}
+	}
+}
+
+// Accepts reports whether a handler of the chain handles the node on the top of the stack,
+// by its type and routes. The handlers accepting every type, such as the drivers, are not counted.
+func (c *Chain) Accepts(ctx *Context) bool {
+	top := ctx.SymbolStack.Top()
+	if top == nil {
+		return false
 	}
-	ctx.HandlerIndex++
-	if ctx.SymbolStack.Top().GetType()&c.Handlers[index].Type() != 0 && satisfy(ctx, c.Handlers[index]) {
-		c.Handlers[index].Handle(c, ctx, f)
+	for i, h := range c.Handlers {
+		if h.Type() != math.MaxInt && top.GetType()&h.Type() != 0 && c.satisfy(ctx, i) {
+			return true
+		}
 	}
+	return false
 }
 
-func satisfy(ctx *Context, handler Handler) bool {
-	return true
+func (c *Chain) satisfy(ctx *Context, index int) bool {
+	return c.routeOf(index).match(ctx)
 }
+
+// routeOf returns the compiled route of Handlers[index]. The routes are compiled once, and
+// again once Handlers changed, whether through AddHandler or not.
+func (c *Chain) routeOf(index int) *route {
+	r := c.routing.Load()
+	if r == nil || len(r.handlers) != len(c.Handlers) || !sameHandler(r.handlers[index], c.Handlers[index]) {
+		r = &routing{handlers: make([]Handler, len(c.Handlers)), routes: make([]*route, len(c.Handlers))}
+		copy(r.handlers, c.Handlers)
+		for i, h := range r.handlers {
+			r.routes[i] = newRoute(h)
+		}
+		c.routing.Store(r)
+	}
+	return r.routes[index]
+}
+
+// sameHandler reports whether a and b are the same handler. Handlers of types which
+// cannot be compared are never the same, their routes are compiled every time.
+func sameHandler(a, b Handler) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t != nil && t.Comparable() && a == b
+}
+
 func CreateChain(chainName string, handlers ...Handler) (*Chain, error) {
 	c := &Chain{
 		Name: chainName,
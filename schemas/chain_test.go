@@ -0,0 +1,103 @@
+package schemas_test
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// lastHandler always chooses the last alternative, on the nodes selected by its routes.
+type lastHandler struct {
+	ids   []string
+	paths []string
+}
+
+func (h *lastHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	children := ctx.CurrentNode.GetSymbols()
+	ctx.ResultBuffer = append(ctx.ResultBuffer, children[0])
+	chain.Next(ctx, cb)
+}
+
+func (h *lastHandler) HookRoute() []regexp.Regexp {
+	res := make([]regexp.Regexp, 0)
+	for _, id := range h.ids {
+		res = append(res, *regexp.MustCompile(id))
+	}
+	return res
+}
+
+func (h *lastHandler) PathRoute() []string {
+	return h.paths
+}
+
+func (h *lastHandler) Name() string {
+	return "last"
+}
+
+func (h *lastHandler) Type() schemas.GrammarType {
+	return schemas.GrammarOR
+}
+
+func terminals(ctx *schemas.Context) string {
+	res := make([]string, 0)
+	for _, n := range ctx.SymbolStack.GetTrace() {
+		if n.GetType() == schemas.GrammarTerminal {
+			res = append(res, strings.Trim(n.GetContent(), "'"))
+		}
+	}
+	sort.Strings(res)
+	return strings.Join(res, "")
+}
+
+func TestChainRoute(t *testing.T) {
+	g := createTwoProductionGrammar()
+	tests := []struct {
+		name string
+		last *lastHandler
+		want string
+	}{
+		{"no route", &lastHandler{}, "xz"},
+		{"matching id", &lastHandler{ids: []string{"^Q#0$"}}, "xz"},
+		{"other id", &lastHandler{ids: []string{"^P"}}, "x"},
+		{"matching path", &lastHandler{paths: []string{"P/Q"}}, "xz"},
+		{"other path", &lastHandler{paths: []string{"Q/P"}}, "x"},
+		{"either", &lastHandler{ids: []string{"^P"}, paths: []string{"P//Q"}}, "xz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the type of the first handlers never matches OR nodes, they have to be skipped
+			chain, err := schemas.CreateChain("route", &driveHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, tt.last)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := terminals(generate(t, g, "P", chain)); got != tt.want {
+				t.Errorf("generated %q, want %q", got, tt.want)
+			}
+			// chains built without AddHandler are routed as well
+			chain = &schemas.Chain{Name: "route", Handlers: chain.Handlers}
+			if got := terminals(generate(t, g, "P", chain)); got != tt.want {
+				t.Errorf("generated %q without precompiled routes, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChainRouteChangedHandlers(t *testing.T) {
+	g := createTwoProductionGrammar()
+	chain := &schemas.Chain{Name: "route", Handlers: []schemas.Handler{&driveHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &lastHandler{}}}
+	if got := terminals(generate(t, g, "P", chain)); got != "xz" {
+		t.Fatalf("generated %q, want %q", got, "xz")
+	}
+	// the routes compiled for the former handler must not be used for the new one
+	chain.Handlers[3] = &lastHandler{ids: []string{"^P"}}
+	if got := terminals(generate(t, g, "P", chain)); got != "x" {
+		t.Errorf("generated %q after replacing the handler, want %q", got, "x")
+	}
+	clone := chain.Clone()
+	if got := terminals(generate(t, g, "P", &clone)); got != "x" {
+		t.Errorf("generated %q with the clone, want %q", got, "x")
+	}
+}
@@ -0,0 +1,155 @@
+package schemas
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// Checkpoint is a saved state of a Context, see Context.Checkpoint.
+type Checkpoint struct {
+	journal        int
+	edges          int
+	values         int
+	symbols        int
+	visitedEdge    map[string]int
+	memoryExchange map[string]int
+	storage        *memdb.MemDB
+	mode           Mode
+	reserved       completion
+	reserving      bool
+	choiceSeq      int
+	recorded       []Choice
+	tmp            []string
+	tmp1           []string
+}
+
+// clone copies the stack.
+func (q *Stack) clone() *Stack {
+	copies := make(map[*frame]*frame)
+	var cp func(f *frame) *frame
+	cp = func(f *frame) *frame {
+		if f == nil {
+			return nil
+		}
+		if c, ok := copies[f]; ok {
+			return c
+		}
+		c := *f
+		c.parts = slices.Clone(f.parts)
+		copies[f] = &c
+		c.parent = cp(f.parent)
+		return &c
+	}
+	res := &Stack{
+		q:               slices.Clone(q.q),
+		trace:           slices.Clone(q.trace),
+		ProductionTrace: slices.Clone(q.ProductionTrace),
+		frames:          make([]*frame, len(q.frames)),
+		count:           maps.Clone(q.count),
+		current:         cp(q.current),
+		expansions:      q.expansions,
+		tokens:          q.tokens,
+		ctx:             q.ctx,
+	}
+	for i, f := range q.frames {
+		res.frames[i] = cp(f)
+	}
+	return res
+}
+
+// Checkpoint saves the state of the generation: the symbol stack, the derivation,
+// VisitedEdge, MemoryExchange and the storage. Rollback restores it, any number of times.
+// The symbol stack, the derivation and the size plans are not copied: from the first
+// checkpoint on, their writes are journaled, and Rollback undoes the ones made since.
+// VisitedEdge and MemoryExchange, which the handlers write directly, are copied.
+func (c *Context) Checkpoint() *Checkpoint {
+	c.journaling = true
+	return &Checkpoint{
+		journal:        len(c.journal),
+		edges:          len(c.Result.EdgeHistory),
+		values:         len(c.Result.valueHistory),
+		symbols:        len(c.Result.symbolHistory),
+		visitedEdge:    maps.Clone(c.VisitedEdge),
+		memoryExchange: maps.Clone(c.MemoryExchange),
+		storage:        c.Storage.Snapshot(),
+		mode:           c.Mode,
+		reserved:       c.reserved,
+		reserving:      c.reserving,
+		choiceSeq:      c.choiceSeq,
+		recorded:       c.recorded[:len(c.recorded):len(c.recorded)],
+		tmp:            c.tmp[:len(c.tmp):len(c.tmp)],
+		tmp1:           c.Tmp1[:len(c.Tmp1):len(c.Tmp1)],
+	}
+}
+
+// onRollback journals undo, which reverts a write made after the first checkpoint, see Checkpoint.
+func (c *Context) onRollback(undo func()) {
+	if c == nil || !c.journaling {
+		return
+	}
+	c.journal = append(c.journal, undo)
+}
+
+// Rollback restores the state saved by cp and clears the error, so that the generation can go on from there.
+// Checkpoints taken after cp must not be used anymore.
+func (c *Context) Rollback(cp *Checkpoint) {
+	for len(c.journal) > cp.journal {
+		c.journal[len(c.journal)-1]()
+		c.journal[len(c.journal)-1] = nil
+		c.journal = c.journal[:len(c.journal)-1]
+	}
+	c.Result.truncate(cp.edges, cp.values, cp.symbols)
+	c.VisitedEdge = maps.Clone(cp.visitedEdge)
+	c.MemoryExchange = maps.Clone(cp.memoryExchange)
+	c.Storage = cp.storage.Snapshot()
+	c.Mode = cp.mode
+	c.reserved = cp.reserved
+	c.reserving = cp.reserving
+	for len(c.choices) > 0 && c.choices[len(c.choices)-1].seq >= cp.choiceSeq {
+		c.choices = c.choices[:len(c.choices)-1]
+	}
+	c.recorded = cp.recorded
+	c.tmp = cp.tmp
+	c.Tmp1 = cp.tmp1
+	c.CurrentNode = nil
+	c.ResultBuffer = nil
+	c.HandlerIndex = 0
+	c.Error = nil
+	c.finish = false
+}
+
+// truncate removes the edges, the values and the symbol counts recorded after the first ones,
+// and the derived nodes left without edges.
+func (d *Derivation) truncate(edges, values, symbols int) {
+	if edges == len(d.EdgeHistory) && values == len(d.valueHistory) && symbols == len(d.symbolHistory) {
+		return
+	}
+	d.own()
+	for i := len(d.EdgeHistory) - 1; i >= edges; i-- {
+		id := d.EdgeHistory[i]
+		if e := d.internal.GetEdgeById(id); e != nil {
+			d.internal.DeleteEdge(e)
+			to := e.GetTo()
+			if len(d.internal.GetInEdges(to)) == 0 && len(d.internal.GetOutEdges(to)) == 0 {
+				d.internal.DeleteVertex(to)
+			}
+		}
+		from, _ := ExtractEdgeID(id)
+		if d.edgeCnt[from]--; d.edgeCnt[from] <= 0 {
+			delete(d.edgeCnt, from)
+		}
+	}
+	d.EdgeHistory = d.EdgeHistory[:edges]
+	for _, id := range d.valueHistory[values:] {
+		delete(d.values, id)
+	}
+	d.valueHistory = d.valueHistory[:values]
+	for _, id := range d.symbolHistory[symbols:] {
+		if d.SymbolCnt[id]--; d.SymbolCnt[id] <= 0 {
+			delete(d.SymbolCnt, id)
+		}
+	}
+	d.symbolHistory = d.symbolHistory[:symbols]
+}
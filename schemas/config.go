@@ -1,5 +1,245 @@
 package schemas
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+var ErrUnhandledType = errors.New("no handler for the grammar type")
+
+// Config is the former configuration of a generation.
+//
+// Deprecated: the generation does not read it, the chain is configured by a ChainConfig.
 type Config struct {
 	SymbolLimit map[string]int
 }
+
+// ChainConfig describes a chain, it is read from YAML or JSON by LoadChain:
+//
+//	name: main
+//	handlers:
+//	  - name: monitor
+//	  - name: mux
+//	    routes:
+//	      Production|Catenate: [{name: cat_handler}]
+//	      REP: [{name: rep_handler, params: {probability: 0.3}}]
+//	    chains:
+//	      - types: OR
+//	        priority: 10
+//	        handlers: [{name: probability_handler}]
+//
+// The order of the handlers is the order of the chain.
+type ChainConfig struct {
+	Name     string          `json:"name" yaml:"name"`
+	Handlers []HandlerConfig `json:"handlers" yaml:"handlers"`
+}
+
+// HandlerConfig is a handler of the registry with its parameters. Routes give the
+// chains of a TypeRouter, keyed by grammar types such as "OR" or "GrammarREP|GrammarPLUS";
+// Chains give them with a priority, for a PriorityRouter.
+type HandlerConfig struct {
+	Name   string                     `json:"name" yaml:"name"`
+	Params map[string]any             `json:"params,omitempty" yaml:"params,omitempty"`
+	Routes map[string][]HandlerConfig `json:"routes,omitempty" yaml:"routes,omitempty"`
+	Chains []RouteConfig              `json:"chains,omitempty" yaml:"chains,omitempty"`
+}
+
+type RouteConfig struct {
+	Types    string          `json:"types" yaml:"types"`
+	Priority int             `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Handlers []HandlerConfig `json:"handlers" yaml:"handlers"`
+}
+
+// TypeRouter is implemented by handlers routing every node to a chain of its grammar type, like handlers.MuxHandler.
+type TypeRouter interface {
+	Route(t GrammarType, chain *Chain) error
+	Routes() GrammarType // the types with a chain
+}
+
+// PriorityRouter is a TypeRouter with several chains per type, tried by decreasing priority.
+type PriorityRouter interface {
+	TypeRouter
+	RouteWithPriority(t GrammarType, chain *Chain, priority int) error
+}
+
+// ParseChainConfig reads a ChainConfig from YAML or JSON.
+func ParseChainConfig(data []byte) (*ChainConfig, error) {
+	c := &ChainConfig{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadChain builds the chain described by a config file with the DefaultRegistry.
+// If g is not nil, it checks that every grammar type reachable in g has a handler.
+func LoadChain(filename string, g *Grammar) (*Chain, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseChainConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	chain, err := c.Build(DefaultRegistry)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if g != nil {
+		if err := ValidateChain(chain, g); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	return chain, nil
+}
+
+func (c *ChainConfig) Build(r *Registry) (*Chain, error) {
+	return buildChain(r, c.Name, c.Handlers)
+}
+
+func buildChain(r *Registry, name string, configs []HandlerConfig) (*Chain, error) {
+	chain, err := CreateChain(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, hc := range configs {
+		h, err := r.New(hc.Name, hc.Params)
+		if err != nil {
+			return nil, err
+		}
+		if err := buildRoutes(r, h, hc); err != nil {
+			return nil, err
+		}
+		chain.AddHandler(h)
+	}
+	return chain, nil
+}
+
+func buildRoutes(r *Registry, h Handler, hc HandlerConfig) error {
+	if len(hc.Routes) == 0 && len(hc.Chains) == 0 {
+		return nil
+	}
+	router, ok := h.(TypeRouter)
+	if !ok {
+		return fmt.Errorf("the handler %s has routes but routes no grammar type", hc.Name)
+	}
+	// sort the routes, so that the chains are registered in the same order every time
+	keys := make([]string, 0, len(hc.Routes))
+	for k := range hc.Routes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		t, err := ParseGrammarType(k)
+		if err != nil {
+			return err
+		}
+		sub, err := buildChain(r, k, hc.Routes[k])
+		if err != nil {
+			return err
+		}
+		if err := router.Route(t, sub); err != nil {
+			return err
+		}
+	}
+	if len(hc.Chains) == 0 {
+		return nil
+	}
+	prio, ok := h.(PriorityRouter)
+	if !ok {
+		return fmt.Errorf("the handler %s does not route by priority", hc.Name)
+	}
+	for _, rc := range hc.Chains {
+		t, err := ParseGrammarType(rc.Types)
+		if err != nil {
+			return err
+		}
+		sub, err := buildChain(r, rc.Types, rc.Handlers)
+		if err != nil {
+			return err
+		}
+		if err := prio.RouteWithPriority(t, sub, rc.Priority); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseGrammarType reads a union of grammar types such as "OR|GrammarREP", with or without the "Grammar" prefix.
+func ParseGrammarType(s string) (GrammarType, error) {
+	var res GrammarType
+	for _, name := range strings.Split(s, "|") {
+		name = strings.TrimSpace(name)
+		found := false
+		for t, rep := range typeStrRep {
+			if strings.EqualFold(rep, name) || strings.EqualFold(strings.TrimPrefix(rep, "Grammar"), name) {
+				res |= t
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown grammar type %q", name)
+		}
+	}
+	return res, nil
+}
+
+// ValidateChain checks that every grammar type reachable from the start symbol of g
+// is handled by a handler of the chain, or routed by one. Handlers accepting every type are not counted.
+func ValidateChain(c *Chain, g *Grammar) error {
+	missing := g.reachableTypes() &^ handledTypes(c)
+	if missing == 0 {
+		return nil
+	}
+	names := make([]string, 0)
+	for t, rep := range typeStrRep {
+		if missing&t != 0 {
+			names = append(names, rep)
+		}
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%w: %s", ErrUnhandledType, strings.Join(names, ", "))
+}
+
+func handledTypes(c *Chain) GrammarType {
+	var res GrammarType
+	for _, h := range c.Handlers {
+		if t := h.Type(); t != math.MaxInt {
+			res |= t
+		}
+		if r, ok := h.(TypeRouter); ok {
+			res |= r.Routes()
+		}
+	}
+	return res
+}
+
+// reachableTypes is the union of the types of the nodes reachable from the start symbol.
+func (g *Grammar) reachableTypes() GrammarType {
+	var res GrammarType
+	visited := make(map[string]bool)
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n == nil || visited[n.GetID()] {
+			return
+		}
+		visited[n.GetID()] = true
+		res |= n.GetType()
+		if n.GetType() == GrammarID {
+			walk(g.GetNode(n.GetContent()))
+		}
+		for _, child := range n.GetSymbols() {
+			walk(child)
+		}
+	}
+	walk(g.GetNode(g.GetStartSym()))
+	return res
+}
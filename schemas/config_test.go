@@ -0,0 +1,75 @@
+package schemas_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func TestRegistry(t *testing.T) {
+	h, err := schemas.DefaultRegistry.New(schemas.RepHandlerName, map[string]any{"probability": 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep, ok := h.(*schemas.RepHandler); !ok || rep.Probability != 0.5 {
+		t.Errorf("got %#v, want a RepHandler with the probability 0.5", h)
+	}
+	if _, err := schemas.DefaultRegistry.New("nothing", nil); !errors.Is(err, schemas.ErrUnknownHandler) {
+		t.Errorf("got error %v, want an unknown handler", err)
+	}
+	if _, err := schemas.DefaultRegistry.New(schemas.RepHandlerName, map[string]any{"probabilty": 0.5}); err == nil {
+		t.Error("a misspelt parameter should be reported")
+	}
+}
+
+func TestChainConfig(t *testing.T) {
+	g := createTwoProductionGrammar()
+	g.BuildShortestNotation()
+	// JSON is read as well as YAML
+	c, err := schemas.ParseChainConfig([]byte(`{"name": "json", "handlers": [
+		{"name": "backtrack_handler", "params": {"MaxRepeat": 2}},
+		{"name": "cat_handler"}, {"name": "or_handler"}, {"name": "id_handler"}, {"name": "terminal_handler"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := c.Build(schemas.DefaultRegistry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schemas.ValidateChain(chain, g); err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, g)
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	if res := ctx.Result.GetResult(nil); res != "xy" && res != "xz" {
+		t.Errorf("got %q", res)
+	}
+
+	c, err = schemas.ParseChainConfig([]byte("name: yaml\nhandlers:\n  - name: backtrack_handler\n  - name: cat_handler\n  - name: id_handler\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain, err = c.Build(schemas.DefaultRegistry); err != nil {
+		t.Fatal(err)
+	}
+	if err := schemas.ValidateChain(chain, g); !errors.Is(err, schemas.ErrUnhandledType) {
+		t.Errorf("got error %v, want the OR nodes to be unhandled", err)
+	}
+}
+
+func TestParseGrammarType(t *testing.T) {
+	typ, err := schemas.ParseGrammarType("OR | GrammarREP|plus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != schemas.GrammarOR|schemas.GrammarREP|schemas.GrammarPLUS {
+		t.Errorf("got %b", typ)
+	}
+	if _, err := schemas.ParseGrammarType("ORR"); err == nil {
+		t.Error("an unknown type should be reported")
+	}
+}
@@ -3,6 +3,9 @@ package schemas
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
 	"strings"
 
 	"github.com/hashicorp/go-memdb"
@@ -18,18 +21,89 @@ type Stack struct {
 	q               []*Node
 	trace           []*Node
 	ProductionTrace []string
+
+	frames     []*frame      // frames[i] records where q[i] sits in the derivation tree
+	count      map[*Node]int // occurrences of every node in q
+	current    *frame        // the frame popped last, i.e. the parent of the symbols pushed next
+	expansions int
+	tokens     int
+	ctx        *Context // runs the edge hooks, nil for a bare stack
+}
+
+// frame links a symbol of the stack to the symbol it was expanded from.
+type frame struct {
+	node   *Node
+	depth  int
+	parent *frame
+
+	index   int      // position among the children of parent
+	pending int      // children whose subtree is not fully expanded yet
+	parts   []string // rendered text of the children
+	done    bool
 }
 
 func (q *Stack) Push(g ...*Node) *Stack {
 	if g == nil {
 		panic(g)
 	}
+	for _, n := range g {
+		f := &frame{node: n, parent: q.current}
+		if q.current != nil {
+			f.depth = q.current.depth + 1
+			f.index = len(q.current.parts)
+			q.current.parts = append(q.current.parts, "")
+			q.current.pending++
+			q.ctx.preHooks(f)
+		}
+		q.frames = append(q.frames, f)
+		if q.count == nil {
+			q.count = make(map[*Node]int)
+		}
+		q.count[n]++
+		if q.ctx != nil && q.ctx.reserving {
+			q.ctx.reserveNode(n, 1)
+		}
+		q.ctx.onRollback(func() {
+			q.q = q.q[:len(q.q)-1]
+			q.frames = q.frames[:len(q.frames)-1]
+			q.uncount(n)
+			if f.parent != nil {
+				f.parent.parts = f.parent.parts[:f.index]
+				f.parent.pending--
+			}
+		})
+	}
 	q.q = append(q.q, g...)
 	return q
 }
 
+// uncount removes an occurrence of the node from the count of the stack.
+func (q *Stack) uncount(n *Node) {
+	if q.count[n]--; q.count[n] <= 0 {
+		delete(q.count, n)
+	}
+}
+
 func (q *Stack) Pop() *Stack {
+	q.settle()
 	curSym := q.q[len(q.q)-1]
+	prev, traced := q.current, len(q.ProductionTrace)
+	q.current = q.frames[len(q.frames)-1]
+	q.frames = q.frames[:len(q.frames)-1]
+	q.expansions++
+	q.uncount(curSym)
+	if q.ctx != nil && q.ctx.reserving {
+		q.ctx.reserveNode(curSym, -1)
+	}
+	if curSym != nil && curSym.GetType() == GrammarTerminal {
+		q.tokens++
+		if q.ctx != nil {
+			// the text is chosen once, so that every rendering of the derivation writes the same
+			if _, err := q.ctx.terminalValue(q.ctx.Result.idOf(curSym), curSym); err != nil && q.ctx.Error == nil {
+				q.ctx.Error = fmt.Errorf("%s: %w", curSym.GetID(), err)
+			}
+		}
+	}
 	q.trace = append(q.trace, curSym)
 	lastSym := ""
 	if len(q.ProductionTrace) > 0 {
@@ -43,9 +117,71 @@ func (q *Stack) Pop() *Stack {
 		q.ProductionTrace = append(q.ProductionTrace, strings.Split(strings.TrimSpace(curSym.GetID()), "#")[0])
 	}
 	q.q = q.q[:len(q.q)-1]
+	f := q.current
+	q.ctx.onRollback(func() {
+		q.q = append(q.q, curSym)
+		q.frames = append(q.frames, f)
+		q.current = prev
+		q.expansions--
+		q.count[curSym]++
+		if curSym != nil && curSym.GetType() == GrammarTerminal {
+			q.tokens--
+		}
+		q.trace = q.trace[:len(q.trace)-1]
+		q.ProductionTrace = q.ProductionTrace[:traced]
+	})
 	return q
 }
 
+// settle completes the frame popped last if it was not expanded into any child.
+func (q *Stack) settle() {
+	if q.current != nil && !q.current.done && q.current.pending == 0 {
+		q.complete(q.current)
+	}
+}
+
+// complete runs the post hooks of a fully expanded subtree and of every ancestor it completes.
+func (q *Stack) complete(f *frame) {
+	for ; f != nil && f.pending == 0 && !f.done; f = f.parent {
+		f.done = true
+		text := ""
+		if q.ctx.hasPostHooks() {
+			text = q.ctx.Result.textOf(f.node, f.parts)
+			q.ctx.postHooks(f, text)
+		}
+		if f.parent != nil {
+			f.parent.parts[f.index] = text
+			f.parent.pending--
+		}
+		f := f
+		q.ctx.onRollback(func() {
+			f.done = false
+			if f.parent != nil {
+				f.parent.parts[f.index] = ""
+				f.parent.pending++
+			}
+		})
+	}
+}
+
+// path returns the production path from the root of the derivation tree to f, see query.Pattern.
+func (f *frame) path() []string {
+	res := make([]string, 0, f.depth+1)
+	for ; f != nil; f = f.parent {
+		name := strings.Split(f.node.GetID(), "#")[0]
+		if len(res) == 0 || res[len(res)-1] != name {
+			res = append(res, name)
+		}
+	}
+	slices.Reverse(res)
+	return res
+}
+
+// contains tells whether the node is on the stack.
+func (q *Stack) contains(n *Node) bool {
+	return q.count[n] > 0
+}
+
 func (q *Stack) Top() *Node {
 	if len(q.q) > 0 {
 		return q.q[len(q.q)-1]
@@ -63,6 +199,50 @@ func (q *Stack) Empty() bool {
 	}
 	return true
 }
+
+// Depth returns the depth of the top of the stack in the derivation tree.
+func (q *Stack) Depth() int {
+	if len(q.frames) == 0 {
+		return 0
+	}
+	return q.frames[len(q.frames)-1].depth
+}
+
+// Recursion returns the largest number of times a production occurs on the
+// path from the root of the derivation tree to the top of the stack.
+func (q *Stack) Recursion() int {
+	if len(q.frames) == 0 {
+		return 0
+	}
+	res := 0
+	cnt := make(map[string]int)
+	for f := q.frames[len(q.frames)-1]; f != nil; f = f.parent {
+		if f.node != nil && f.node.GetType() == GrammarProduction {
+			cnt[f.node.GetID()]++
+			res = max(res, cnt[f.node.GetID()])
+		}
+	}
+	return res
+}
+
+// Path returns the production path from the root of the derivation tree to the top of the stack.
+func (q *Stack) Path() []string {
+	if len(q.frames) == 0 {
+		return nil
+	}
+	return q.frames[len(q.frames)-1].path()
+}
+
+// Expansions returns the number of symbols popped so far.
+func (q *Stack) Expansions() int {
+	return q.expansions
+}
+
+// Tokens returns the number of terminals popped so far.
+func (q *Stack) Tokens() int {
+	return q.tokens
+}
+
 func (q *Stack) GetTrace() []*Node {
 	return q.trace
 }
@@ -74,6 +254,7 @@ func NewStack() *Stack {
 		q:               make([]*Node, 0),
 		trace:           make([]*Node, 0),
 		ProductionTrace: make([]string, 0),
+		frames:          make([]*frame, 0),
 	}
 }
 
@@ -88,6 +269,9 @@ type Context struct {
 
 	VisitedEdge    map[string]int
 	Mode           Mode
+	Budget         Budget
+	Hooks          *EdgeHooks
+	Rand           *rand.Rand // source of every random choice of the handlers, see WithSeed
 	Constraint     *ConstraintGraph
 	MemoryExchange map[string]int
 
@@ -96,6 +280,20 @@ type Context struct {
 	Error        error
 	tmp          []string
 	Tmp1         []string
+
+	sizePlan    map[*Node]int         // target sizes of pending nodes, see UniformSizeHandler
+	completions map[string]completion // shortest completion costs, see Budget
+	reserved    completion            // shortest completion of the symbol stack, see reserve
+	reserving   bool                  // whether reserved is kept up to date
+	choices     []*choicePoint        // choices to backtrack to, see BacktrackHandler
+	choiceSeq   int
+	backtracks  int
+	recorded    []Choice // see RecordHandler
+	recording   bool
+	streams     map[*StreamHandler]*stream // see StreamHandler
+	replays     map[*ReplayHandler]*replay // see ReplayHandler
+	journal     []func()                   // undoes the writes made since the first checkpoint, see Checkpoint
+	journaling  bool
 }
 
 type NodeRuntimeInfo struct {
@@ -142,18 +340,24 @@ func NewContext(grammarMap *Grammar, startSymbol string, ctx context.Context, co
 		db = gendb()
 	}
 
-	return &Context{
+	c := &Context{
 		Grammar:     grammarMap,
 		Context:     ctx, // 使用带有超时的context
 		SymbolStack: NewStack().Push(node),
 		Storage:     db,
 		VisitedEdge: map[string]int{},
 		Result: &Derivation{
-			Grammar:     NewGrammar(WithStartSym(startSymbol)),
+			Grammar:     newDerivationGrammar(startSymbol),
+			origin:      grammarMap,
 			EdgeHistory: make([]string, 0),
 			SymbolCnt:   make(map[string]int),
 		},
 		Constraint:     cons,
 		MemoryExchange: map[string]int{},
-	}, nil
+		Rand:           rand.New(rand.NewSource(rand.Int63())),
+	}
+	c.SymbolStack.ctx = c
+	// the start symbol is the only node of the stack not derived from another one
+	c.Result.nodes = map[string]*Node{startSymbol + "#0": node}
+	return c, nil
 }
@@ -4,12 +4,23 @@ import (
 	"fmt"
 	"github.com/lucasjones/reggen"
 	"strings"
+	"sync/atomic"
 )
 
 type Derivation struct {
 	*Grammar
+	origin      *Grammar // grammar the nodes are derived from
 	EdgeHistory []string
 	SymbolCnt   map[string]int // 为了给语法图上的Node做标记
+	edgeCnt     map[string]int // order of the edges leaving each derived node, kept here so the grammar is never written
+	shared      *atomic.Int32  // number of forks sharing the graph, see Context.Fork
+
+	ids          map[*Node]string  // derived node of every node pushed on the stack
+	nodes        map[string]*Node  // node of the stack of every derived node, the inverse of ids
+	values       map[string]string // text chosen for the derived nodes, see ProviderHandler
+	valueHistory []string
+
+	symbolHistory []string // symbols whose count AddNode raised, in order, see Context.Rollback
 }
 
 func (d *Derivation) getNodeID(id string) string {
@@ -21,6 +32,7 @@ func (d *Derivation) getNodeID(id string) string {
 func (d *Derivation) AddNode(node *Node) {
 	if d.internal.GetVertexById(d.getNodeID(node.GetID())) != nil { // already exists
 		d.SymbolCnt[node.GetID()]++
+		d.symbolHistory = append(d.symbolHistory, node.GetID())
 	}
 	newnode := node.Clone(d.Grammar)
 	newnode.SetID(d.getNodeID(node.GetID()))
@@ -28,56 +40,151 @@ func (d *Derivation) AddNode(node *Node) {
 
 // AddEdge convention: When adding a Edge, by AddEdge, we first AddNode to the graph
 func (d *Derivation) AddEdge(from, to *Node) {
+	d.own()
 	newfrom := from.Clone(d.Grammar)
 	newto := to.Clone(d.Grammar)
 
-	newfrom.SetID(d.getNodeID(from.GetID()))
-	newto.SetID(d.getNodeID(to.GetID()))
-	from.SetMeta(from.GetMeta() + 1)
+	// from was derived earlier, its count may be behind the latest one of its symbol
+	newfrom.SetID(d.idOf(from))
+	if from == to {
+		newto.SetID(newfrom.GetID())
+	} else {
+		newto.SetID(d.getNodeID(to.GetID()))
+	}
+	if d.edgeCnt == nil {
+		d.edgeCnt = make(map[string]int)
+	}
+	d.edgeCnt[newfrom.GetID()]++
+	newfrom.SetMeta(from.GetMeta() + d.edgeCnt[newfrom.GetID()])
 
 	newfrom.AddSymbol(newto)
 	d.EdgeHistory = append(d.EdgeHistory, GetEdgeID(newfrom.GetID(), newto.GetID()))
+	if from != to {
+		if d.ids == nil {
+			d.ids = make(map[*Node]string)
+		}
+		d.ids[to] = newto.GetID()
+		if d.nodes == nil {
+			d.nodes = make(map[string]*Node)
+		}
+		d.nodes[newto.GetID()] = to
+	}
+}
+
+// idOf returns the derived node of the node n of the stack.
+func (d *Derivation) idOf(n *Node) string {
+	if id, ok := d.ids[n]; ok {
+		return id
+	}
+	return d.getNodeID(n.GetID())
 }
+
+// setValue records the text of the node n of the stack, GetResult writes it instead of its derivation.
+func (d *Derivation) setValue(n *Node, value string) {
+	d.setValueID(d.idOf(n), value)
+}
+
+// textOf returns the text of the complete node n of the stack: its value, or else the
+// text of the terminal, or else the texts of its children, parts.
+func (d *Derivation) textOf(n *Node, parts []string) string {
+	if value, ok := d.values[d.idOf(n)]; ok {
+		return value
+	}
+	if n.GetType() == GrammarTerminal {
+		return renderTerminal(n.GetContent())
+	}
+	return strings.Join(parts, "")
+}
+
+func (d *Derivation) setValueID(id string, value string) {
+	d.own()
+	if d.values == nil {
+		d.values = make(map[string]string)
+	}
+	d.values[id] = value
+	d.valueHistory = append(d.valueHistory, id)
+}
+
 func isTermPreserve(content string) bool {
 	return (content[0] == content[len(content)-1]) && ((content[0] == '\'') || content[0] == '"')
 }
 
+// terminals generates the terminals given as regular expressions, see Context.terminalValue.
+var terminals RegexProvider
+
+// terminalValue returns the text of the terminal n, whose derived node is id. The text of a regular
+// expression is generated once, from the random source of the Context, and recorded in the
+// derivation, so that GetResult, Render and Tree write it again instead of generating another one.
+func (c *Context) terminalValue(id string, n *Node) (string, error) {
+	if value, ok := c.Result.values[id]; ok {
+		return value, nil
+	}
+	if !isRegexTerminal(n.GetContent()) {
+		return renderTerminal(n.GetContent()), nil
+	}
+	value, err := terminals.Provide(c, n)
+	if err != nil {
+		return "", err
+	}
+	c.Result.setValueID(id, value)
+	return value, nil
+}
+
+// renderTerminal returns the text of a terminal: quoted literals are unquoted and
+// double-quoted ones are regular expressions, generated at random.
+func renderTerminal(content string) string {
+	if !isTermPreserve(content) {
+		return content
+	}
+	tmp := strings.Trim(content, "'\"")
+	switch content[0] {
+	case '"':
+		res, err := reggen.Generate(tmp, 10)
+		if err != nil {
+			panic(err)
+		}
+		return res
+	case '\'':
+		return tmp
+	default:
+		panic("error in generating terminal")
+	}
+}
+
+// root returns the root of the derivation, a lone node if the start symbol derives nothing, e.g. a terminal.
+func (d *Derivation) root() *Node {
+	id := d.Grammar.GetStartSym() + "#0"
+	if n := d.Grammar.GetNode(id); n != nil || d.origin == nil {
+		return n
+	}
+	sym := d.origin.GetNode(d.Grammar.GetStartSym())
+	if sym == nil {
+		return nil
+	}
+	n := sym.Clone(d.Grammar)
+	n.SetID(id)
+	return n
+}
+
 func (d *Derivation) GetResult(custom func(content string) string) string {
-	root := d.Grammar.GetNode(d.Grammar.GetStartSym() + "#0")
+	root := d.root()
 	if root == nil {
 		return ""
 	}
-	res := ""
-	d.SymbolCnt = make(map[string]int)
-
+	res := &strings.Builder{}
 	dfs(root, func(cur *Node) {
-		if cur.GetType() == GrammarTerminal {
-			content := cur.GetContent()
-			if isTermPreserve(content) {
-				tmp := strings.Trim(content, "'\"")
-				switch content[0] {
-				case '"':
-					var err error
-					content, err = reggen.Generate(tmp, 10)
-					if err != nil {
-						panic(err)
-					}
-				case '\'':
-					content = tmp
-				default:
-					panic("error in generating terminal")
-				}
+		if value, ok := d.values[cur.GetID()]; ok {
+			if custom != nil {
+				value = custom(value)
 			}
+			res.WriteString(value)
+		} else if cur.GetType() == GrammarTerminal {
+			content := renderTerminal(cur.GetContent())
 			if custom != nil {
 				content = custom(content)
 			}
-			res += content
-			d.SymbolCnt[cur.GetID()]++
+			res.WriteString(content)
 		}
 	})
-	return res
-}
-
-func (d *Derivation) Visualize() {
-
+	return res.String()
 }
@@ -5,3 +5,9 @@ type Output map[string]interface{}
 type Environment interface {
 	Interact(input Input) (Output, error)
 }
+
+// Keys of the Output of an Environment read by the mutation scheduler, see mutation.Scheduler.
+const (
+	OutputNewCoverage = "new_coverage" // the input covered new code: true, or the number of new edges
+	OutputCrash       = "crash"        // the input crashed the target: true, or a description of the crash
+)
@@ -21,6 +21,13 @@ func marshalGrammar(g *Grammar) ([]byte, error) {
 		}
 		meta, _ := anypb.New(&ffi.IntValue{Value: uint64(vv)})
 		prop := v.GetProperty(Prop)
+		var repeatProb map[int32]float64
+		if prop.RepeatProb != nil {
+			repeatProb = make(map[int32]float64)
+			for k, p := range prop.RepeatProb {
+				repeatProb[int32(k)] = p
+			}
+		}
 		vertexMap[v.GetID()] = &ffi.FSVertex{
 			Id: v.GetID(),
 			PropertyMap: map[string]*ffi.Property{
@@ -28,6 +35,9 @@ func marshalGrammar(g *Grammar) ([]byte, error) {
 					Type:               uint64(prop.Type),
 					Content:            prop.Content,
 					DistanceToTerminal: int32(prop.DistanceToTerminal),
+					ChoiceProb:         prop.ChoiceProb,
+					RepeatProb:         repeatProb,
+					Lexical:            prop.Lexical,
 				},
 			},
 			Meta: meta,
@@ -72,11 +82,21 @@ func unmarshalGrammar(data []byte) (*Grammar, error) {
 	for _, v := range graphData.VertexMap {
 		n := graph.NewVertex[Property]()
 		n.SetID(v.Id)
+		var repeatProb map[int]float64
+		if v.PropertyMap[Prop].RepeatProb != nil {
+			repeatProb = make(map[int]float64)
+			for k, p := range v.PropertyMap[Prop].RepeatProb {
+				repeatProb[int(k)] = p
+			}
+		}
 		n.SetProperty(Prop, Property{
 			Type:               GrammarType(v.PropertyMap[Prop].Type),
 			Gram:               grammar,
 			Content:            v.PropertyMap[Prop].Content,
 			DistanceToTerminal: int(v.PropertyMap[Prop].DistanceToTerminal),
+			ChoiceProb:         v.PropertyMap[Prop].ChoiceProb,
+			RepeatProb:         repeatProb,
+			Lexical:            v.PropertyMap[Prop].Lexical,
 		})
 		meta := &ffi.IntValue{}
 		_ = v.Meta.UnmarshalTo(meta)
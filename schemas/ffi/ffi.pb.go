@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.28.1
+// 	protoc-gen-go v1.32.0
 // 	protoc        v3.20.3
 // source: ffi.proto
 
@@ -239,10 +239,13 @@ type Property struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Type               uint64 `protobuf:"varint,1,opt,name=type,proto3" json:"type,omitempty"`
-	Root               string `protobuf:"bytes,2,opt,name=root,proto3" json:"root,omitempty"`
-	Content            string `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
-	DistanceToTerminal int32  `protobuf:"varint,5,opt,name=distanceToTerminal,proto3" json:"distanceToTerminal,omitempty"`
+	Type               uint64             `protobuf:"varint,1,opt,name=type,proto3" json:"type,omitempty"`
+	Root               string             `protobuf:"bytes,2,opt,name=root,proto3" json:"root,omitempty"`
+	Content            string             `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	DistanceToTerminal int32              `protobuf:"varint,5,opt,name=distanceToTerminal,proto3" json:"distanceToTerminal,omitempty"`
+	ChoiceProb         map[string]float64 `protobuf:"bytes,6,rep,name=choiceProb,proto3" json:"choiceProb,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"`
+	RepeatProb         map[int32]float64  `protobuf:"bytes,7,rep,name=repeatProb,proto3" json:"repeatProb,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"`
+	Lexical            bool               `protobuf:"varint,8,opt,name=lexical,proto3" json:"lexical,omitempty"`
 }
 
 func (x *Property) Reset() {
@@ -305,6 +308,27 @@ func (x *Property) GetDistanceToTerminal() int32 {
 	return 0
 }
 
+func (x *Property) GetChoiceProb() map[string]float64 {
+	if x != nil {
+		return x.ChoiceProb
+	}
+	return nil
+}
+
+func (x *Property) GetRepeatProb() map[int32]float64 {
+	if x != nil {
+		return x.RepeatProb
+	}
+	return nil
+}
+
+func (x *Property) GetLexical() bool {
+	if x != nil {
+		return x.Lexical
+	}
+	return false
+}
+
 type FSEdgeList struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -552,29 +576,45 @@ var file_ffi_proto_rawDesc = []byte{
 	0x6f, 0x70, 0x65, 0x72, 0x74, 0x79, 0x4d, 0x61, 0x70, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
 	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
 	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x7c, 0x0a, 0x08, 0x50, 0x72,
-	0x6f, 0x70, 0x65, 0x72, 0x74, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f,
-	0x6f, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x12, 0x18,
-	0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x2e, 0x0a, 0x12, 0x64, 0x69, 0x73, 0x74,
-	0x61, 0x6e, 0x63, 0x65, 0x54, 0x6f, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x18, 0x05,
-	0x20, 0x01, 0x28, 0x05, 0x52, 0x12, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x54, 0x6f,
-	0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x22, 0x2b, 0x0a, 0x0a, 0x46, 0x53, 0x45, 0x64,
-	0x67, 0x65, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x05, 0x65, 0x64, 0x67, 0x65, 0x73, 0x18,
-	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x07, 0x2e, 0x46, 0x53, 0x45, 0x64, 0x67, 0x65, 0x52, 0x05,
-	0x65, 0x64, 0x67, 0x65, 0x73, 0x22, 0x21, 0x0a, 0x09, 0x42, 0x6f, 0x6f, 0x6c, 0x56, 0x61, 0x6c,
-	0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x08, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x20, 0x0a, 0x08, 0x49, 0x6e, 0x74, 0x56,
-	0x61, 0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x04, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x23, 0x0a, 0x0b, 0x53, 0x74,
-	0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
-	0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x42,
-	0x38, 0x5a, 0x36, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x43, 0x55,
-	0x48, 0x4b, 0x2d, 0x53, 0x45, 0x2d, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x2f, 0x67, 0x65, 0x6e, 0x65,
-	0x72, 0x69, 0x63, 0x2d, 0x67, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2f, 0x73, 0x63,
-	0x68, 0x65, 0x6d, 0x61, 0x73, 0x2f, 0x66, 0x66, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x8a, 0x03, 0x0a, 0x08, 0x50,
+	0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x72,
+	0x6f, 0x6f, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x12,
+	0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x2e, 0x0a, 0x12, 0x64, 0x69, 0x73,
+	0x74, 0x61, 0x6e, 0x63, 0x65, 0x54, 0x6f, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x12, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x54,
+	0x6f, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x68, 0x6f,
+	0x69, 0x63, 0x65, 0x50, 0x72, 0x6f, 0x62, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e,
+	0x50, 0x72, 0x6f, 0x70, 0x65, 0x72, 0x74, 0x79, 0x2e, 0x43, 0x68, 0x6f, 0x69, 0x63, 0x65, 0x50,
+	0x72, 0x6f, 0x62, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x63, 0x68, 0x6f, 0x69, 0x63, 0x65,
+	0x50, 0x72, 0x6f, 0x62, 0x12, 0x39, 0x0a, 0x0a, 0x72, 0x65, 0x70, 0x65, 0x61, 0x74, 0x50, 0x72,
+	0x6f, 0x62, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x50, 0x72, 0x6f, 0x70, 0x65,
+	0x72, 0x74, 0x79, 0x2e, 0x52, 0x65, 0x70, 0x65, 0x61, 0x74, 0x50, 0x72, 0x6f, 0x62, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x65, 0x61, 0x74, 0x50, 0x72, 0x6f, 0x62, 0x12,
+	0x18, 0x0a, 0x07, 0x6c, 0x65, 0x78, 0x69, 0x63, 0x61, 0x6c, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x07, 0x6c, 0x65, 0x78, 0x69, 0x63, 0x61, 0x6c, 0x1a, 0x3d, 0x0a, 0x0f, 0x43, 0x68, 0x6f,
+	0x69, 0x63, 0x65, 0x50, 0x72, 0x6f, 0x62, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3d, 0x0a, 0x0f, 0x52, 0x65, 0x70, 0x65,
+	0x61, 0x74, 0x50, 0x72, 0x6f, 0x62, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x2b, 0x0a, 0x0a, 0x46, 0x53, 0x45, 0x64, 0x67,
+	0x65, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x05, 0x65, 0x64, 0x67, 0x65, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x07, 0x2e, 0x46, 0x53, 0x45, 0x64, 0x67, 0x65, 0x52, 0x05, 0x65,
+	0x64, 0x67, 0x65, 0x73, 0x22, 0x21, 0x0a, 0x09, 0x42, 0x6f, 0x6f, 0x6c, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x20, 0x0a, 0x08, 0x49, 0x6e, 0x74, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x23, 0x0a, 0x0b, 0x53, 0x74, 0x72,
+	0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x38,
+	0x5a, 0x36, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x43, 0x55, 0x48,
+	0x4b, 0x2d, 0x53, 0x45, 0x2d, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x2f, 0x67, 0x65, 0x6e, 0x65, 0x72,
+	0x69, 0x63, 0x2d, 0x67, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x2f, 0x73, 0x63, 0x68,
+	0x65, 0x6d, 0x61, 0x73, 0x2f, 0x66, 0x66, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -589,7 +629,7 @@ func file_ffi_proto_rawDescGZIP() []byte {
 	return file_ffi_proto_rawDescData
 }
 
-var file_ffi_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_ffi_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_ffi_proto_goTypes = []interface{}{
 	(*FSGraph)(nil),     // 0: FSGraph
 	(*FSVertex)(nil),    // 1: FSVertex
@@ -604,26 +644,30 @@ var file_ffi_proto_goTypes = []interface{}{
 	nil,                 // 10: FSGraph.MetadataEntry
 	nil,                 // 11: FSVertex.PropertyMapEntry
 	nil,                 // 12: FSEdge.PropertyMapEntry
-	(*anypb.Any)(nil),   // 13: google.protobuf.Any
+	nil,                 // 13: Property.ChoiceProbEntry
+	nil,                 // 14: Property.RepeatProbEntry
+	(*anypb.Any)(nil),   // 15: google.protobuf.Any
 }
 var file_ffi_proto_depIdxs = []int32{
 	8,  // 0: FSGraph.edgeMap:type_name -> FSGraph.EdgeMapEntry
 	9,  // 1: FSGraph.vertexMap:type_name -> FSGraph.VertexMapEntry
 	10, // 2: FSGraph.metadata:type_name -> FSGraph.MetadataEntry
 	11, // 3: FSVertex.propertyMap:type_name -> FSVertex.PropertyMapEntry
-	13, // 4: FSVertex.meta:type_name -> google.protobuf.Any
+	15, // 4: FSVertex.meta:type_name -> google.protobuf.Any
 	12, // 5: FSEdge.propertyMap:type_name -> FSEdge.PropertyMapEntry
-	13, // 6: FSEdge.meta:type_name -> google.protobuf.Any
-	2,  // 7: FSEdgeList.edges:type_name -> FSEdge
-	2,  // 8: FSGraph.EdgeMapEntry.value:type_name -> FSEdge
-	1,  // 9: FSGraph.VertexMapEntry.value:type_name -> FSVertex
-	13, // 10: FSGraph.MetadataEntry.value:type_name -> google.protobuf.Any
-	3,  // 11: FSVertex.PropertyMapEntry.value:type_name -> Property
-	12, // [12:12] is the sub-list for method output_type
-	12, // [12:12] is the sub-list for method input_type
-	12, // [12:12] is the sub-list for extension type_name
-	12, // [12:12] is the sub-list for extension extendee
-	0,  // [0:12] is the sub-list for field type_name
+	15, // 6: FSEdge.meta:type_name -> google.protobuf.Any
+	13, // 7: Property.choiceProb:type_name -> Property.ChoiceProbEntry
+	14, // 8: Property.repeatProb:type_name -> Property.RepeatProbEntry
+	2,  // 9: FSEdgeList.edges:type_name -> FSEdge
+	2,  // 10: FSGraph.EdgeMapEntry.value:type_name -> FSEdge
+	1,  // 11: FSGraph.VertexMapEntry.value:type_name -> FSVertex
+	15, // 12: FSGraph.MetadataEntry.value:type_name -> google.protobuf.Any
+	3,  // 13: FSVertex.PropertyMapEntry.value:type_name -> Property
+	14, // [14:14] is the sub-list for method output_type
+	14, // [14:14] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
 }
 
 func init() { file_ffi_proto_init() }
@@ -735,7 +779,7 @@ func file_ffi_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_ffi_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   13,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
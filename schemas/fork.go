@@ -0,0 +1,123 @@
+package schemas
+
+import (
+	"maps"
+	"math/rand"
+	"slices"
+	"sync/atomic"
+
+	"github.com/CUHK-SE-Group/generic-generator/graph"
+)
+
+// Fork returns an independent copy of the Context, to explore several continuations
+// of one partial generation. The fork gets its own symbol stack, derivation, VisitedEdge,
+// MemoryExchange, storage snapshot and random source, seeded from the Context's one.
+// It is cheap: the derivation graph is shared until either side adds an edge, and the
+// storage is an immutable snapshot. The choices recorded by BacktrackHandler are not
+// forked, a fork never backtracks past the point it was forked from.
+func (c *Context) Fork() *Context {
+	f := *c
+	f.SymbolStack = c.SymbolStack.clone()
+	f.SymbolStack.ctx = &f
+	f.Result = c.Result.fork()
+	f.Storage = c.Storage.Snapshot()
+	f.VisitedEdge = maps.Clone(c.VisitedEdge)
+	f.MemoryExchange = maps.Clone(c.MemoryExchange)
+	f.sizePlan = maps.Clone(c.sizePlan)
+	f.completions = maps.Clone(c.completions)
+	f.Rand = rand.New(rand.NewSource(c.Rand.Int63()))
+	f.tmp = c.tmp[:len(c.tmp):len(c.tmp)]
+	f.Tmp1 = c.Tmp1[:len(c.Tmp1):len(c.Tmp1)]
+	f.recorded = c.recorded[:len(c.recorded):len(c.recorded)]
+	f.ResultBuffer = nil
+	f.choices = nil
+	f.streams = nil
+	f.replays = make(map[*ReplayHandler]*replay, len(c.replays))
+	for h, r := range c.replays {
+		f.replays[h] = &replay{pos: r.pos, divergences: slices.Clone(r.divergences)}
+	}
+	f.journal, f.journaling = nil, false
+	return &f
+}
+
+// fork shares the derivation graph with the copy, structurally: either side only copies
+// what it writes, see graph.SharedGraph. The values are shared until written, see own.
+func (d *Derivation) fork() *Derivation {
+	if d.shared == nil {
+		d.shared = new(atomic.Int32)
+		d.shared.Store(1)
+	}
+	d.shared.Add(1)
+	return &Derivation{
+		Grammar:       d.Grammar.fork(),
+		origin:        d.origin,
+		EdgeHistory:   slices.Clone(d.EdgeHistory),
+		SymbolCnt:     maps.Clone(d.SymbolCnt),
+		edgeCnt:       maps.Clone(d.edgeCnt),
+		shared:        d.shared,
+		ids:           d.ids,
+		nodes:         d.nodes,
+		values:        d.values,
+		valueHistory:  slices.Clone(d.valueHistory),
+		symbolHistory: slices.Clone(d.symbolHistory),
+	}
+}
+
+// own copies the values before they are written if they are shared with forks.
+func (d *Derivation) own() {
+	if d.shared == nil {
+		return
+	}
+	if d.shared.Load() > 1 {
+		d.ids = maps.Clone(d.ids)
+		d.nodes = maps.Clone(d.nodes)
+		d.values = maps.Clone(d.values)
+	}
+	// the copy is made before leaving, the last one left writes the shared values
+	d.shared.Add(-1)
+	d.shared = nil
+}
+
+// newDerivationGrammar makes the grammar of a derivation, whose graph its forks share.
+func newDerivationGrammar(startSym string) *Grammar {
+	g := &Grammar{internal: graph.NewGraph[string, Property](graph.WithShared(true))}
+	g.internal.SetMetadata(StartSym, startSym)
+	return g
+}
+
+// fork returns a copy of the grammar sharing its graph if it is a graph.SharedGraph, a deep copy otherwise.
+// The nodes read from the copy belong to it, though their vertices may be shared.
+func (g *Grammar) fork() *Grammar {
+	if shared, ok := g.internal.(interface {
+		Fork() graph.Graph[string, Property]
+	}); ok {
+		return &Grammar{internal: shared.Fork()}
+	}
+	return g.copy()
+}
+
+// copy deep-copies the graph of the grammar, with the nodes belonging to the copy.
+func (g *Grammar) copy() *Grammar {
+	res := &Grammar{internal: graph.NewGraph[string, Property](graph.WithPersistent(true))}
+	for k, v := range g.internal.GetAllMetadata() {
+		res.internal.SetMetadata(k, v)
+	}
+	vertices := make(map[string]graph.Vertex[Property])
+	for _, v := range g.internal.GetAllVertices() {
+		n := (&Node{internal: v}).Clone(res)
+		res.internal.AddVertex(n.internal)
+		vertices[v.GetID()] = n.internal
+	}
+	for _, e := range g.internal.GetAllEdges() {
+		c := graph.NewEdge[string, Property]()
+		c.SetID(e.GetID())
+		c.SetFrom(vertices[e.GetFrom().GetID()])
+		c.SetTo(vertices[e.GetTo().GetID()])
+		for k, v := range e.GetAllProperties() {
+			c.SetProperty(k, v)
+		}
+		c.SetMeta(e.GetMeta())
+		res.internal.AddEdge(c)
+	}
+	return res
+}
@@ -0,0 +1,110 @@
+package schemas_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func TestFork(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	g.BuildShortestNotation()
+	chain, err := schemas.CreateChain("fork", &driveHandler{}, &schemas.CatHandler{}, &schemas.OrHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// generate until a few symbols are pending, so that the forks can differ
+	var ctx *schemas.Context
+	for ctx == nil || len(ctx.SymbolStack.GetStack()) < 3 {
+		ctx = newContext(t, g)
+		ctx.Budget = schemas.Budget{MaxTokens: 12}
+		for !ctx.GetFinish() && len(ctx.SymbolStack.GetStack()) < 3 {
+			chain.Next(ctx, func(result *schemas.Result) {})
+			ctx.HandlerIndex = 0
+		}
+	}
+	edges := len(ctx.Result.EdgeHistory)
+
+	forks := make([]*schemas.Context, 1000)
+	for i := range forks {
+		forks[i] = ctx.Fork()
+	}
+	wg := sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(forks); i += 8 {
+				if _, err := schemas.Generate(forks[i], chain); err != nil {
+					t.Error(err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if len(ctx.Result.EdgeHistory) != edges {
+		t.Fatalf("the forks changed the derivation of the parent, %d edges instead of %d", len(ctx.Result.EdgeHistory), edges)
+	}
+	results := make(map[string]bool)
+	for _, f := range forks {
+		res := f.Result.GetResult(nil)
+		if res != strings.Repeat("a", countTerminals(f)) {
+			t.Fatalf("the derivation %q does not match the trace of the fork", res)
+		}
+		results[res] = true
+	}
+	if len(results) < 2 {
+		t.Error("every fork generated the same instance, the random sources are shared")
+	}
+
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	if res := ctx.Result.GetResult(nil); res != strings.Repeat("a", countTerminals(ctx)) {
+		t.Errorf("the derivation %q of the parent does not match its trace", res)
+	}
+}
+
+func TestForkSharesGraph(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	g.BuildShortestNotation()
+	chain, err := schemas.CreateChain("fork", &driveHandler{}, &schemas.CatHandler{}, &schemas.OrHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ctx *schemas.Context
+	for ctx == nil || len(ctx.SymbolStack.GetStack()) < 3 {
+		ctx = newContext(t, g)
+		ctx.Budget = schemas.Budget{MaxTokens: 12}
+		for !ctx.GetFinish() && len(ctx.SymbolStack.GetStack()) < 3 {
+			chain.Next(ctx, func(result *schemas.Result) {})
+			ctx.HandlerIndex = 0
+		}
+	}
+	parent := ctx.Result.GetInternal().GetAllVertices()
+
+	fork := ctx.Fork()
+	if _, err := schemas.Generate(fork, chain); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	// the vertices derived before the fork are the same ones on both sides, none was copied
+	for _, v := range parent {
+		if fork.Result.GetInternal().GetVertexById(v.GetID()) != v {
+			t.Fatalf("the vertex %s was copied by the fork", v.GetID())
+		}
+		if ctx.Result.GetInternal().GetVertexById(v.GetID()) != v {
+			t.Fatalf("the vertex %s was copied by the parent", v.GetID())
+		}
+	}
+	for _, c := range []*schemas.Context{ctx, fork} {
+		if res := c.Result.GetResult(nil); res != strings.Repeat("a", countTerminals(c)) {
+			t.Errorf("the derivation %q does not match its trace", res)
+		}
+	}
+}
@@ -1 +1,56 @@
 package schemas
+
+import "errors"
+
+type GenerateOptions struct {
+	Retry int
+}
+
+type GenerateOption func(*GenerateOptions)
+
+// WithRetry rolls a failed generation back to the state it started from and tries again, up to n times.
+// Only dead ends, unsatisfiable constraints and exceeded budgets are retried.
+func WithRetry(n int) GenerateOption {
+	return func(o *GenerateOptions) {
+		o.Retry = n
+	}
+}
+
+// Generate drives the chain until the generation of ctx finishes or fails.
+// The first handler of the chain pops the symbol stack, as in a Pool.
+func Generate(ctx *Context, chain *Chain, opts ...GenerateOption) (*Result, error) {
+	options := GenerateOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	var cp *Checkpoint
+	if options.Retry > 0 {
+		cp = ctx.Checkpoint()
+	}
+	for attempt := 0; ; attempt++ {
+		res := run(ctx, chain)
+		err := res.GetError()
+		if err == nil || attempt >= options.Retry || !retryable(err) {
+			return res, err
+		}
+		ctx.Rollback(cp)
+	}
+}
+
+func run(ctx *Context, chain *Chain) *Result {
+	res := NewResult(ctx)
+	for !ctx.GetFinish() {
+		if ctx.Context != nil && ctx.Error == nil {
+			ctx.Error = ctx.Context.Err()
+		}
+		chain.Next(ctx, func(result *Result) {
+			res = result
+		})
+		ctx.HandlerIndex = 0
+	}
+	return res
+}
+
+func retryable(err error) bool {
+	return errors.Is(err, ErrDeadEnd) || errors.Is(err, ErrConstraintUnsatisfiable) || errors.Is(err, ErrBudgetExceeded)
+}
@@ -0,0 +1,107 @@
+package schemas_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// deadEndHandler chooses an alternative at random and fails when it is 'y'.
+type deadEndHandler struct{}
+
+func (h *deadEndHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	children := ctx.CurrentNode.GetSymbols()
+	child := children[ctx.Rand.Intn(len(children))]
+	if child.GetContent() == "'y'" {
+		ctx.Error = schemas.ErrDeadEnd
+		return
+	}
+	ctx.ResultBuffer = append(ctx.ResultBuffer, child)
+	chain.Next(ctx, cb)
+}
+
+func (h *deadEndHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *deadEndHandler) Name() string {
+	return "dead_end"
+}
+
+func (h *deadEndHandler) Type() schemas.GrammarType {
+	return schemas.GrammarOR
+}
+
+func newContext(t *testing.T, g *schemas.Grammar) *schemas.Context {
+	ctx, err := schemas.NewContext(g, g.GetStartSym(), context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+func TestGenerateError(t *testing.T) {
+	g := createTwoProductionGrammar()
+	g.GetNode("P#2").SetContent("R")
+	chain, err := schemas.CreateChain("error", &driveHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.OrHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, g)
+	res, err := schemas.Generate(ctx, chain, schemas.WithRetry(3))
+	if !errors.Is(err, schemas.ErrDeadEnd) || !errors.Is(res.GetError(), schemas.ErrDeadEnd) {
+		t.Errorf("got error %v, want a dead end", err)
+	}
+	if !ctx.GetFinish() {
+		t.Error("the generation should be finished")
+	}
+
+	g = createTwoProductionGrammar()
+	g.BuildShortestNotation()
+	ctx = newContext(t, g)
+	ctx.Budget = schemas.Budget{MaxDepth: 2}
+	if _, err := schemas.Generate(ctx, chain); !errors.Is(err, schemas.ErrBudgetExceeded) {
+		t.Errorf("got error %v, want the budget to be exceeded", err)
+	}
+
+	ctx = newContext(t, g)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx.Context = cancelled
+	if _, err := schemas.Generate(ctx, chain, schemas.WithRetry(3)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want the cancellation", err)
+	}
+}
+
+func TestGenerateRetry(t *testing.T) {
+	g := createTwoProductionGrammar()
+	chain, err := schemas.CreateChain("retry", &driveHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &deadEndHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := 0
+	for i := 0; i < 50; i++ {
+		ctx := newContext(t, g)
+		if _, err := schemas.Generate(ctx, chain); err != nil {
+			failed++
+		}
+		ctx = newContext(t, g)
+		if _, err := schemas.Generate(ctx, chain, schemas.WithRetry(math.MaxInt)); err != nil {
+			t.Fatal(err)
+		}
+		// the failed attempts must leave no trace
+		if got := ctx.Result.GetResult(nil); got != "xz" {
+			t.Errorf("generated %q, want xz", got)
+		}
+		if got := ctx.SymbolStack.Expansions(); got != 7 {
+			t.Errorf("expanded %d symbols, want 7", got)
+		}
+	}
+	if failed == 0 {
+		t.Error("generations without retry never failed")
+	}
+}
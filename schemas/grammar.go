@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -56,6 +57,9 @@ type Property struct {
 	Gram               *Grammar
 	Content            string
 	DistanceToTerminal int
+	ChoiceProb         map[string]float64 // probability of each alternative of GrammarOR, keyed by node id
+	RepeatProb         map[int]float64    // probability of each repetition count of GrammarREP and GrammarPLUS
+	Lexical            bool               // the production is a lexical one, see Grammar.InferLexical
 }
 
 type Options struct {
@@ -76,6 +80,8 @@ func WithLoadFromFile(filename string) Option {
 	}
 }
 
+// Grammar is safe for concurrent readers. Once generation starts it must be
+// treated as read-only: handlers keep their state in the Context.
 type Grammar struct {
 	internal graph.Graph[string, Property]
 }
@@ -116,7 +122,7 @@ func (g *Grammar) GetStartSym() string {
 
 func (g *Grammar) GetNode(id string) *Node {
 	if inter := g.internal.GetVertexById(id); inter != nil {
-		return &Node{internal: inter}
+		return &Node{internal: inter, gram: g}
 	}
 	return nil
 }
@@ -126,6 +132,26 @@ func (g *Grammar) GetEdge(id string) (*Node, *Node) {
 	return g.GetNode(from), g.GetNode(to)
 }
 
+// Clone returns a deep copy of the grammar, which can be edited without changing g.
+func (g *Grammar) Clone() *Grammar {
+	return g.copy()
+}
+
+// RemoveNode removes the node id and its edges from the grammar, it reports whether the node existed.
+func (g *Grammar) RemoveNode(id string) bool {
+	v := g.internal.GetVertexById(id)
+	if v == nil {
+		return false
+	}
+	edges := slices.Clone(g.internal.GetOutEdges(v))
+	edges = append(edges, g.internal.GetInEdges(v)...)
+	for _, e := range edges {
+		g.internal.DeleteEdge(e)
+	}
+	g.internal.DeleteVertex(v)
+	return true
+}
+
 func (p *Grammar) MergeProduction() {
 	start := p.internal.GetMetadata(StartSym).(string)
 	queue := []*Node{p.GetNode(start)}
@@ -236,6 +262,7 @@ func (g *Grammar) BuildShortestNotation() {
 
 type Node struct {
 	internal graph.Vertex[Property]
+	gram     *Grammar // grammar the node was read from, the vertex may be shared by forks of it, see Grammar.fork
 }
 
 func dfs(node *Node, visit func(*Node)) {
@@ -328,6 +355,9 @@ func (g *Node) SetType(t GrammarType) {
 }
 
 func (g *Node) GetGrammar() *Grammar {
+	if g.gram != nil {
+		return g.gram
+	}
 	return g.internal.GetProperty(Prop).Gram
 }
 
@@ -336,6 +366,18 @@ func (g *Node) AddSymbol(new *Node) int {
 	g.GetGrammar().internal.AddEdge(e)
 	return len(g.GetGrammar().internal.GetOutEdges(g.internal)) - 1
 }
+
+// RemoveSymbol removes child from the symbols of the node, it reports whether child was one of them.
+func (g *Node) RemoveSymbol(child *Node) bool {
+	for _, e := range g.GetGrammar().internal.GetOutEdges(g.internal) {
+		if e.GetTo().GetID() == child.GetID() {
+			g.GetGrammar().internal.DeleteEdge(e)
+			return true
+		}
+	}
+	return false
+}
+
 func getNumber(id string) int {
 	ids := strings.Split(id, "#")
 	if len(ids) != 2 {
@@ -349,12 +391,13 @@ func getNumber(id string) int {
 	return num1
 }
 func (g *Node) GetSymbols() []*Node {
-	edges := g.GetGrammar().internal.GetOutEdges(g.internal)
+	// sort a copy, the index of the graph is shared by concurrent generations
+	edges := slices.Clone(g.GetGrammar().internal.GetOutEdges(g.internal))
 	sort.Slice(edges, func(i, j int) bool {
 		return edges[i].GetMeta().(int) > edges[j].GetMeta().(int)
 	})
 	f := func(edge graph.Edge[string, Property]) *Node {
-		return &Node{internal: edge.GetTo()}
+		return &Node{internal: edge.GetTo(), gram: g.gram}
 	}
 	ori := A.Map(f)(edges)
 	return ori
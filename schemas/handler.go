@@ -1,10 +1,9 @@
 package schemas
 
 import (
+	"errors"
 	"fmt"
-	"log/slog"
 	"math"
-	"math/rand"
 	"regexp"
 	"strings"
 )
@@ -22,6 +21,15 @@ const (
 	OptionHandlerName   = "option_handler"
 )
 
+// Errors reported by handlers. A handler that cannot expand the current node sets
+// Context.Error, usually wrapping one of these, and returns without calling
+// Chain.Next; the chain then stops and surfaces the error through the Result.
+var (
+	ErrDeadEnd                 = errors.New("no derivation can continue from the current node")
+	ErrConstraintUnsatisfiable = errors.New("the constraint cannot be satisfied")
+	ErrBudgetExceeded          = errors.New("the generation budget is exceeded")
+)
+
 type Handler interface {
 	Handle(*Chain, *Context, ResponseCallBack)
 	HookRoute() []regexp.Regexp
@@ -69,8 +77,17 @@ func (h *OrHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
 		chain.Next(ctx, cb)
 		return
 	}
-	idx := rand.Int() % len(ctx.CurrentNode.GetSymbols())
-	ctx.ResultBuffer = append(ctx.ResultBuffer, ctx.CurrentNode.GetSymbol(idx))
+	if ctx.Shrinking() {
+		ctx.ResultBuffer = append(ctx.ResultBuffer, shortestSymbol(ctx.CurrentNode))
+		chain.Next(ctx, cb)
+		return
+	}
+	candidates := ctx.fitting(ctx.CurrentNode.GetSymbols())
+	if len(candidates) == 0 {
+		ctx.ResultBuffer = append(ctx.ResultBuffer, shortestSymbol(ctx.CurrentNode))
+	} else {
+		ctx.ResultBuffer = append(ctx.ResultBuffer, candidates[ctx.Rand.Intn(len(candidates))])
+	}
 
 	//ctx.Result.AddNode((cur.GetSymbols())[idx])
 	//ctx.Result.AddEdge(cur, (cur.GetSymbols())[idx])
@@ -97,8 +114,8 @@ func (h *IDHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
 	// 是Identifier, 那么去找新的production
 	node := ctx.Grammar.GetNode(ctx.CurrentNode.GetContent())
 	if node == nil {
-		slog.Error("The identifier does not Existed", "id", ctx.CurrentNode.GetContent())
-		return // omit error
+		ctx.Error = fmt.Errorf("%w: the identifier %s does not exist", ErrDeadEnd, ctx.CurrentNode.GetContent())
+		return
 	}
 	//ctx.Result.AddNode(node)
 	//ctx.Result.AddEdge(ctx.CurrentNode, node)
@@ -119,11 +136,16 @@ func (h *IDHandler) Type() GrammarType {
 }
 
 type RepHandler struct {
+	Probability float64 // probability to repeat once, 0.1 by default
 }
 
 func (r *RepHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
 	// 默认设置 10% 的概率来重复一次
-	if rand.Intn(10) > 8 {
+	p := r.Probability
+	if p == 0 {
+		p = 0.1
+	}
+	if ctx.Rand.Float64() < p && !ctx.Shrinking() && ctx.Fits(ctx.CurrentNode.GetSymbols()...) {
 		ctx.ResultBuffer = append(ctx.ResultBuffer, ctx.CurrentNode.GetSymbols()...)
 		//for _, node := range ctx.CurrentNode.GetSymbols() {
 		//	ctx.Result.AddNode(node)
@@ -164,7 +186,7 @@ func (h *TermHandler) stripQuote(content string) string {
 
 func (h *TermHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
 	if len(ctx.CurrentNode.GetSymbols()) != 0 {
-		slog.Error("Pattern mismatched[Terminal]")
+		ctx.Error = fmt.Errorf("%w: the terminal %s has children", ErrDeadEnd, ctx.CurrentNode.GetID())
 		return
 	}
 	if len(ctx.tmp) == 0 {
@@ -201,11 +223,11 @@ func (h *BracketHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack)
 	//ctx.SymbolStack.Pop()
 	children := ctx.CurrentNode.GetSymbols()
 	if len(children) == 0 {
-		slog.Error("Pattern mismatched[Identifier]")
+		ctx.Error = fmt.Errorf("%w: %s has no children", ErrDeadEnd, ctx.CurrentNode.GetID())
 		return
 	}
 	// todo, 注释这段代码。这段代码是为了测试
-	if strings.Contains(ctx.CurrentNode.GetContent(), "SP") {
+	if strings.Contains(ctx.CurrentNode.GetContent(), "SP") && !ctx.Shrinking() && ctx.Fits(children...) {
 		for i := len(children) - 1; i >= 0; i-- {
 			//ctx.SymbolStack.Push(children[i])
 			ctx.ResultBuffer = append(ctx.ResultBuffer, children[i])
@@ -238,12 +260,19 @@ func (h *PlusHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
 	//ctx.SymbolStack.Pop()
 	children := ctx.CurrentNode.GetSymbols()
 	if len(children) == 0 {
-		slog.Error("Pattern mismatched[Identifier]")
+		ctx.Error = fmt.Errorf("%w: %s has no children", ErrDeadEnd, ctx.CurrentNode.GetID())
 		return
 	}
-	for j := 0; j < rand.Intn(10)+1; j++ {
+	cnt := ctx.Rand.Intn(10) + 1
+	if ctx.Shrinking() {
+		cnt = 1
+	}
+	for cnt > 1 && !ctx.Fits(repeatSymbols(children, cnt)...) {
+		cnt--
+	}
+	for j := 0; j < cnt; j++ {
 		for i := len(children) - 1; i >= 0; i-- {
-			ctx.ResultBuffer = append(ctx.ResultBuffer, children[i])
+			ctx.ResultBuffer = append(ctx.ResultBuffer, &Node{internal: children[i].internal})
 		}
 	}
 
@@ -0,0 +1,119 @@
+package schemas
+
+import (
+	"regexp"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas/query"
+)
+
+// PreHook is called before the child to is pushed onto the symbol stack.
+type PreHook func(ctx *Context, from, to *Node)
+
+// PostHook is called once the subtree of to is fully expanded, text is the rendered text of the subtree.
+type PostHook func(ctx *Context, from, to *Node, text string)
+
+// EdgePattern selects edges of the derivation tree. Empty fields match anything.
+type EdgePattern struct {
+	From string // regular expression on the ID of the parent
+	To   string // regular expression on the ID of the child
+	Path string // PathQuery expression on the production path from the root to the child, e.g. "program//where_clause"
+}
+
+type edgeMatcher struct {
+	from *regexp.Regexp
+	to   *regexp.Regexp
+	path query.Pattern
+}
+
+func compileEdgePattern(p EdgePattern) (*edgeMatcher, error) {
+	m := &edgeMatcher{path: query.Compile(p.Path)}
+	var err error
+	if p.From != "" {
+		if m.from, err = regexp.Compile("^(?:" + p.From + ")$"); err != nil {
+			return nil, err
+		}
+	}
+	if p.To != "" {
+		if m.to, err = regexp.Compile("^(?:" + p.To + ")$"); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *edgeMatcher) match(f *frame) bool {
+	if m.from != nil && !m.from.MatchString(f.parent.node.GetID()) {
+		return false
+	}
+	if m.to != nil && !m.to.MatchString(f.node.GetID()) {
+		return false
+	}
+	return m.path == nil || m.path.Match(f.path())
+}
+
+// EdgeHooks holds the hooks run on the edges of the derivation tree, set it as Context.Hooks.
+// The hooks are registered before the generation starts, a registry may be shared by many contexts.
+type EdgeHooks struct {
+	pre  []preHook
+	post []postHook
+}
+
+type preHook struct {
+	m  *edgeMatcher
+	fn PreHook
+}
+
+type postHook struct {
+	m  *edgeMatcher
+	fn PostHook
+}
+
+func NewEdgeHooks() *EdgeHooks {
+	return &EdgeHooks{}
+}
+
+// Pre registers fn to run before a child matching p is pushed.
+func (h *EdgeHooks) Pre(p EdgePattern, fn PreHook) error {
+	m, err := compileEdgePattern(p)
+	if err != nil {
+		return err
+	}
+	h.pre = append(h.pre, preHook{m: m, fn: fn})
+	return nil
+}
+
+// Post registers fn to run after the subtree of a child matching p is fully expanded.
+func (h *EdgeHooks) Post(p EdgePattern, fn PostHook) error {
+	m, err := compileEdgePattern(p)
+	if err != nil {
+		return err
+	}
+	h.post = append(h.post, postHook{m: m, fn: fn})
+	return nil
+}
+
+func (c *Context) preHooks(f *frame) {
+	if c == nil || c.Hooks == nil {
+		return
+	}
+	for _, h := range c.Hooks.pre {
+		if h.m.match(f) {
+			h.fn(c, f.parent.node, f.node)
+		}
+	}
+}
+
+func (c *Context) hasPostHooks() bool {
+	return c != nil && c.Hooks != nil && len(c.Hooks.post) != 0
+}
+
+func (c *Context) postHooks(f *frame, text string) {
+	if f.parent == nil {
+		return
+	}
+	for _, h := range c.Hooks.post {
+		if h.m.match(f) {
+			h.fn(c, f.parent.node, f.node, text)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package schemas_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// createTwoProductionGrammar builds P = 'x', Q; Q = 'y' | 'z'.
+func createTwoProductionGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("P"))
+	p := schemas.NewNode(g, schemas.GrammarProduction, "P", "'x', Q")
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "P#0", "'x', Q")
+	q := schemas.NewNode(g, schemas.GrammarProduction, "Q", "'y' | 'z'")
+	or := schemas.NewNode(g, schemas.GrammarOR, "Q#0", "'y' | 'z'")
+	p.AddSymbol(cat)
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "P#1", "'x'"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "P#2", "Q"))
+	q.AddSymbol(or)
+	or.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "Q#1", "'y'"))
+	or.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "Q#2", "'z'"))
+	return g
+}
+
+func generateWithHooks(t *testing.T, g *schemas.Grammar, hooks *schemas.EdgeHooks) *schemas.Context {
+	chain, err := schemas.CreateChain("hooks", &driveHandler{routes: []schemas.Handler{
+		&schemas.CatHandler{}, &schemas.OrHandler{}, &schemas.IDHandler{},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := schemas.NewContext(g, g.GetStartSym(), context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Hooks = hooks
+	// the binary tree grammar has no bound on its size otherwise
+	ctx.Budget = schemas.Budget{MaxTokens: 50}
+	for !ctx.GetFinish() {
+		chain.Next(ctx, func(result *schemas.Result) {
+			ctx = result.GetCtx()
+		})
+		ctx.HandlerIndex = 0
+	}
+	return ctx
+}
+
+func TestEdgeHooks(t *testing.T) {
+	g := createTwoProductionGrammar()
+	hooks := schemas.NewEdgeHooks()
+	pre := make([]string, 0)
+	if err := hooks.Pre(schemas.EdgePattern{From: "P#0"}, func(ctx *schemas.Context, from, to *schemas.Node) {
+		pre = append(pre, to.GetID())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	whole := ""
+	if err := hooks.Post(schemas.EdgePattern{From: "P", To: "P#0"}, func(ctx *schemas.Context, from, to *schemas.Node, text string) {
+		whole = text
+	}); err != nil {
+		t.Fatal(err)
+	}
+	inQ := make([]string, 0)
+	if err := hooks.Post(schemas.EdgePattern{Path: "P/Q"}, func(ctx *schemas.Context, from, to *schemas.Node, text string) {
+		inQ = append(inQ, from.GetID()+"->"+to.GetID()+":"+text)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hooks.Pre(schemas.EdgePattern{To: "("}, nil); err == nil {
+		t.Error("an invalid regular expression should be rejected")
+	}
+
+	for i := 0; i < 20; i++ {
+		pre, whole, inQ = pre[:0], "", inQ[:0]
+		generateWithHooks(t, g, hooks)
+		if strings.Join(pre, " ") != "P#1 P#2" {
+			t.Errorf("pre hooks ran on %v, want P#1 P#2", pre)
+		}
+		if whole != "xy" && whole != "xz" {
+			t.Errorf("the text of P is %q", whole)
+		}
+		want := []string{"Q#0->Q#" + map[string]string{"y": "1", "z": "2"}[whole[1:]] + ":" + whole[1:], "Q->Q#0:" + whole[1:], "P#2->Q:" + whole[1:]}
+		if strings.Join(inQ, " ") != strings.Join(want, " ") {
+			t.Errorf("post hooks under P/Q ran as %v, want %v", inQ, want)
+		}
+	}
+}
+
+func TestEdgeHooksText(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	g.BuildShortestNotation()
+	hooks := schemas.NewEdgeHooks()
+	var texts []string
+	if err := hooks.Post(schemas.EdgePattern{From: "S#[34]", To: "S"}, func(ctx *schemas.Context, from, to *schemas.Node, text string) {
+		texts = append(texts, text)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	root := ""
+	if err := hooks.Post(schemas.EdgePattern{From: "S", To: "S#0"}, func(ctx *schemas.Context, from, to *schemas.Node, text string) {
+		root = text
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		texts, root = texts[:0], ""
+		ctx := generateWithHooks(t, g, hooks)
+		if root != strings.Repeat("a", countTerminals(ctx)) {
+			t.Errorf("the text of the root is %q for %d terminals", root, countTerminals(ctx))
+		}
+		for _, text := range texts {
+			if text == "" || strings.Trim(text, "a") != "" {
+				t.Errorf("unexpected subtree text %q", text)
+			}
+		}
+		ids := 0
+		for _, n := range ctx.SymbolStack.GetTrace() {
+			if n.GetType() == schemas.GrammarID {
+				ids++
+			}
+		}
+		if len(texts) != ids {
+			t.Errorf("post hooks ran on %d subtrees, want one per identifier, %d", len(texts), ids)
+		}
+	}
+}
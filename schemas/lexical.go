@@ -0,0 +1,104 @@
+package schemas
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var lexicalName = regexp.MustCompile(`^[\p{Lu}][\p{Lu}\p{N}_]*$`)
+
+// IsLexical tells whether the node is a lexical production, e.g. IDENTIFIER, whose text is a single token.
+func (g *Node) IsLexical() bool {
+	return g.internal.GetProperty(Prop).Lexical
+}
+
+func (g *Node) SetLexical(lexical bool) {
+	p := g.internal.GetProperty(Prop)
+	p.Lexical = lexical
+	g.internal.SetProperty(Prop, p)
+}
+
+// IsLexicalName is the naming convention of the lexical productions: upper-case names, like IDENTIFIER or SP.
+func IsLexicalName(name string) bool {
+	return lexicalName.MatchString(name)
+}
+
+// InferLexical marks the productions named by the convention of IsLexicalName as lexical, and the others as syntactic.
+func (g *Grammar) InferLexical() {
+	for _, v := range g.internal.GetAllVertices() {
+		n := &Node{internal: v}
+		if n.GetType() == GrammarProduction {
+			n.SetLexical(IsLexicalName(n.GetID()))
+		}
+	}
+}
+
+// SetLexical marks the productions named as lexical, or as syntactic, whatever their names.
+func (g *Grammar) SetLexical(lexical bool, names ...string) error {
+	for _, name := range names {
+		n := g.GetNode(name)
+		if n == nil || n.GetType() != GrammarProduction {
+			return fmt.Errorf("%w: production %s", ErrSymbolNotFound, name)
+		}
+		n.SetLexical(lexical)
+	}
+	return nil
+}
+
+// IsLexical tells whether the node id of the grammar is part of a lexical production.
+func (g *Grammar) IsLexical(id string) bool {
+	n := g.GetNode(trimNumber(id))
+	return n != nil && n.IsLexical()
+}
+
+// Coverage counts the edges of the grammar used by derivations. The edges of the lexical
+// productions are counted apart: the characters of an identifier say little of the syntax covered.
+type Coverage struct {
+	Edges          int
+	Covered        int
+	LexicalEdges   int
+	LexicalCovered int
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 1
+	}
+	return float64(a) / float64(b)
+}
+
+// Syntactic is the ratio of the syntactic edges covered.
+func (c Coverage) Syntactic() float64 {
+	return ratio(c.Covered, c.Edges)
+}
+
+// Lexical is the ratio of the lexical edges covered.
+func (c Coverage) Lexical() float64 {
+	return ratio(c.LexicalCovered, c.LexicalEdges)
+}
+
+// Coverage counts the edges of the grammar used by the derivations.
+func (g *Grammar) Coverage(derivations ...*Derivation) Coverage {
+	used := make(map[string]bool)
+	for _, d := range derivations {
+		for _, e := range d.internal.GetAllEdges() {
+			used[GetEdgeID(origin(e.GetFrom().GetID()), origin(e.GetTo().GetID()))] = true
+		}
+	}
+	res := Coverage{}
+	for _, e := range g.internal.GetAllEdges() {
+		covered := used[GetEdgeID(e.GetFrom().GetID(), e.GetTo().GetID())]
+		if g.IsLexical(e.GetFrom().GetID()) {
+			res.LexicalEdges++
+			if covered {
+				res.LexicalCovered++
+			}
+			continue
+		}
+		res.Edges++
+		if covered {
+			res.Covered++
+		}
+	}
+	return res
+}
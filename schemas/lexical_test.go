@@ -0,0 +1,117 @@
+package schemas_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// createNumberStatementGrammar S = K, N; K = 'let'; N = D, D; D = '1'
+func createNumberStatementGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("S"))
+	s := schemas.NewNode(g, schemas.GrammarProduction, "S", "K, N")
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "S#0", "K, N")
+	s.AddSymbol(cat)
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "S#1", "K"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "S#2", "N"))
+	k := schemas.NewNode(g, schemas.GrammarProduction, "K", "'let'")
+	k.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "K#0", "'let'"))
+	n := schemas.NewNode(g, schemas.GrammarProduction, "N", "D, D")
+	ncat := schemas.NewNode(g, schemas.GrammarCatenate, "N#0", "D, D")
+	n.AddSymbol(ncat)
+	ncat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "N#1", "D"))
+	ncat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "N#2", "D"))
+	d := schemas.NewNode(g, schemas.GrammarProduction, "D", "'1'")
+	d.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "D#0", "'1'"))
+	return g
+}
+
+func TestLexicalTokens(t *testing.T) {
+	g := createNumberStatementGrammar()
+	g.InferLexical()
+	if !g.GetNode("S").IsLexical() || !g.GetNode("N").IsLexical() {
+		t.Fatal("the upper-case names are lexical, as S, K, N and D here")
+	}
+	if err := g.SetLexical(false, "S", "K", "D"); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := schemas.CreateChain("lexical", &driveHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, g)
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	if tokens := ctx.Result.Tokens(); !slices.Equal(tokens, []string{"let", "11"}) {
+		t.Errorf("got the tokens %q", tokens)
+	}
+	if res := ctx.Result.Render(&schemas.Layout{Separator: " "}, nil); res != "let 11" {
+		t.Errorf("got %q", res)
+	}
+
+	c := g.Coverage(ctx.Result)
+	// N, N#0, N#0 to N#1 and N#2 are lexical; S, S#0 to S#1 and S#2, K and D are not
+	if c.LexicalEdges != 3 || c.LexicalCovered != 3 || c.Edges != 5 || c.Covered != 5 {
+		t.Errorf("got %+v", c)
+	}
+	if err := g.SetLexical(true, "X"); err == nil {
+		t.Error("X is not a production")
+	}
+}
+
+func TestParseAndTokenize(t *testing.T) {
+	g := createNumberStatementGrammar()
+	if err := g.SetLexical(true, "N"); err != nil {
+		t.Fatal(err)
+	}
+	if tokens := g.Tokenize("let11?let"); !slices.Equal(tokens, []string{"let", "11", "?", "let"}) {
+		t.Errorf("got the tokens %q", tokens)
+	}
+	// the input is read no further than the token at every position, a long one is split at once
+	if tokens := g.Tokenize(strings.Repeat("let11?", 2000)); len(tokens) != 6000 || tokens[5998] != "11" {
+		t.Errorf("got %d tokens", len(tokens))
+	}
+	d, err := g.Parse("S", "let11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := d.Tree()
+	if err := tree.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if s := tree.SExpr(); s != `(S (K "let") (N (D "1") (D "1")))` || d.GetResult(nil) != "let11" {
+		t.Errorf("got %s", s)
+	}
+	if _, err := g.Parse("S", "let1"); !errors.Is(err, schemas.ErrNoParse) {
+		t.Errorf("got %v", err)
+	}
+}
+
+// createWordGrammar S = "[a-z]+", 'ab'
+func createWordGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("S"))
+	s := schemas.NewNode(g, schemas.GrammarProduction, "S", `"[a-z]+", 'ab'`)
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "S#0", `"[a-z]+", 'ab'`)
+	s.AddSymbol(cat)
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "S#1", `"[a-z]+"`))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "S#2", "'ab'"))
+	return g
+}
+
+func TestParseRegexTerminal(t *testing.T) {
+	// the longest match of the regular expression leaves nothing for 'ab', a shorter one is taken
+	d, err := createWordGrammar().Parse("S", "cdab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.Tree().SExpr(); s != `(S "cd" "ab")` {
+		t.Errorf("got %s", s)
+	}
+	if _, err := createWordGrammar().Parse("S", "cd"); !errors.Is(err, schemas.ErrNoParse) {
+		t.Errorf("got %v", err)
+	}
+}
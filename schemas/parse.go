@@ -0,0 +1,531 @@
+package schemas
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrNoParse = errors.New("the input cannot be derived from the grammar")
+
+// parseNode is a node of the parse tree of an input, spanning input[start:end].
+// For REP and PLUS the children of all repetitions are flattened and repeat
+// holds the number of repetitions.
+type parseNode struct {
+	node     *Node
+	start    int
+	end      int
+	repeat   int
+	children []*parseNode
+}
+
+type earleyItem struct {
+	sym    string
+	alt    int
+	dot    int
+	origin int
+}
+
+// earleyParser is a scannerless Earley parser over the grammar graph. Every
+// grammar node is a nonterminal whose alternatives are derived from its type,
+// terminals are matched directly against the characters of the input.
+type earleyParser struct {
+	g        *Grammar
+	input    string
+	nodes    map[string]*Node
+	alts     map[string][][]string
+	nullable map[string]bool
+	regexps  map[string]*regexTerminal
+	ends     map[matchKey][]int // ends of the matches of the terminals, see matches
+
+	sets      [][]earleyItem
+	seen      []map[earleyItem]bool
+	waiting   []map[string][]earleyItem // waiting[j][sym] holds the items of set j expecting sym
+	completed []map[string][]int        // completed[end][sym] holds the origins of sym
+	building  map[string]bool
+}
+
+func newEarleyParser(g *Grammar) *earleyParser {
+	p := &earleyParser{
+		g:        g,
+		nodes:    make(map[string]*Node),
+		alts:     make(map[string][][]string),
+		nullable: make(map[string]bool),
+		regexps:  make(map[string]*regexTerminal),
+	}
+	for _, v := range g.internal.GetAllVertices() {
+		p.nodes[v.GetID()] = &Node{internal: v}
+	}
+	for id, n := range p.nodes {
+		p.alts[id] = p.alternatives(n)
+	}
+	p.computeNullable()
+	return p
+}
+
+// alternatives rewrites a grammar node into plain BNF alternatives.
+// REP and PLUS become right recursive.
+func (p *earleyParser) alternatives(n *Node) [][]string {
+	children := make([]string, 0)
+	for _, c := range sourceOrder(n.GetSymbols()) {
+		children = append(children, c.GetID())
+	}
+	switch n.GetType() {
+	case GrammarTerminal:
+		return nil
+	case GrammarID:
+		if prod := p.g.GetNode(n.GetContent()); prod != nil {
+			return [][]string{{prod.GetID()}}
+		}
+		return [][]string{}
+	case GrammarOR:
+		res := make([][]string, 0, len(children))
+		for _, c := range children {
+			res = append(res, []string{c})
+		}
+		return res
+	case GrammarOptional, GrammarEXT:
+		return [][]string{{}, children}
+	case GrammarREP:
+		return [][]string{{}, append(append([]string{}, children...), n.GetID())}
+	case GrammarPLUS:
+		return [][]string{children, append(append([]string{}, children...), n.GetID())}
+	case GrammarSUB:
+		if len(children) == 0 {
+			return [][]string{}
+		}
+		return [][]string{{children[0]}}
+	default:
+		return [][]string{children}
+	}
+}
+
+// sourceOrder reverses the symbols returned by Node.GetSymbols, which come
+// last child first, into the order they are written in the grammar.
+func sourceOrder(symbols []*Node) []*Node {
+	res := make([]*Node, len(symbols))
+	for i, n := range symbols {
+		res[len(symbols)-1-i] = n
+	}
+	return res
+}
+
+func (p *earleyParser) computeNullable() {
+	for changed := true; changed; {
+		changed = false
+		for id, n := range p.nodes {
+			if p.nullable[id] {
+				continue
+			}
+			res := false
+			if n.GetType() == GrammarTerminal {
+				res = terminalLiteral(n.GetContent()) == "" && !isRegexTerminal(n.GetContent())
+			}
+			for _, alt := range p.alts[id] {
+				all := true
+				for _, sym := range alt {
+					all = all && p.nullable[sym]
+				}
+				res = res || all
+			}
+			if res {
+				p.nullable[id] = true
+				changed = true
+			}
+		}
+	}
+}
+
+func isRegexTerminal(content string) bool {
+	return len(content) > 1 && content[0] == '"' && content[len(content)-1] == '"'
+}
+
+func terminalLiteral(content string) string {
+	if len(content) > 1 && content[0] == content[len(content)-1] && (content[0] == '\'' || content[0] == '"') {
+		return content[1 : len(content)-1]
+	}
+	return content
+}
+
+// matchKey is a terminal at a position of the input.
+type matchKey struct {
+	sym string
+	i   int
+}
+
+// setInput parses input from now on.
+func (p *earleyParser) setInput(input string) {
+	p.input = input
+	p.ends = make(map[matchKey][]int)
+}
+
+// matches returns the ends of the texts the terminal sym matches at position i, in increasing
+// order. A regular expression may match texts of several lengths, a parse may need any of them.
+func (p *earleyParser) matches(sym string, i int) []int {
+	key := matchKey{sym, i}
+	if res, ok := p.ends[key]; ok {
+		return res
+	}
+	var res []int
+	content := p.nodes[sym].GetContent()
+	if isRegexTerminal(content) {
+		re, ok := p.regexps[sym]
+		if !ok {
+			re, _ = compileRegexTerminal(terminalLiteral(content))
+			p.regexps[sym] = re
+		}
+		if re != nil {
+			for _, n := range re.ends(p.input[i:]) {
+				res = append(res, i+n)
+			}
+		}
+	} else if lit := terminalLiteral(content); strings.HasPrefix(p.input[i:], lit) {
+		res = []int{i + len(lit)}
+	}
+	p.ends[key] = res
+	return res
+}
+
+// regexTerminal matches the regular expression of a terminal at the start of a text, see ends.
+type regexTerminal struct {
+	prog *syntax.Prog
+}
+
+func compileRegexTerminal(pattern string) (*regexTerminal, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	return &regexTerminal{prog: prog}, nil
+}
+
+// threads is a set of instructions of the program, reached at the same position of the text.
+type threads struct {
+	pcs     []uint32 // instructions consuming a rune
+	seen    []bool
+	matched bool
+}
+
+// ends returns the lengths of the prefixes of s the regular expression matches, in increasing
+// order. The program runs as an NFA over s, and stops once no thread is left, so the text is
+// read no further than the longest match.
+func (r *regexTerminal) ends(s string) []int {
+	res := make([]int, 0)
+	cur := &threads{seen: make([]bool, len(r.prog.Inst))}
+	next := &threads{seen: make([]bool, len(r.prog.Inst))}
+	r.add(cur, uint32(r.prog.Start), s, 0)
+	for i := 0; ; {
+		if cur.matched {
+			res = append(res, i)
+		}
+		if i == len(s) || len(cur.pcs) == 0 {
+			return res
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		next.pcs, next.matched = next.pcs[:0], false
+		clear(next.seen)
+		for _, pc := range cur.pcs {
+			inst := &r.prog.Inst[pc]
+			ok := false
+			switch inst.Op {
+			case syntax.InstRune, syntax.InstRune1:
+				ok = inst.MatchRune(c)
+			case syntax.InstRuneAny:
+				ok = true
+			case syntax.InstRuneAnyNotNL:
+				ok = c != '\n'
+			}
+			if ok {
+				r.add(next, inst.Out, s, i+size)
+			}
+		}
+		cur, next = next, cur
+		i += size
+	}
+}
+
+// add adds the instruction pc to the threads at the position i of s, following the empty ones.
+func (r *regexTerminal) add(t *threads, pc uint32, s string, i int) {
+	if t.seen[pc] {
+		return
+	}
+	t.seen[pc] = true
+	inst := &r.prog.Inst[pc]
+	switch inst.Op {
+	case syntax.InstAlt, syntax.InstAltMatch:
+		r.add(t, inst.Out, s, i)
+		r.add(t, inst.Arg, s, i)
+	case syntax.InstNop, syntax.InstCapture:
+		r.add(t, inst.Out, s, i)
+	case syntax.InstEmptyWidth:
+		before, after := rune(-1), rune(-1)
+		if i > 0 {
+			before, _ = utf8.DecodeLastRuneInString(s[:i])
+		}
+		if i < len(s) {
+			after, _ = utf8.DecodeRuneInString(s[i:])
+		}
+		if syntax.EmptyOp(inst.Arg)&^syntax.EmptyOpContext(before, after) == 0 {
+			r.add(t, inst.Out, s, i)
+		}
+	case syntax.InstMatch:
+		t.matched = true
+	case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+		t.pcs = append(t.pcs, pc)
+	}
+}
+
+// match returns the end of the longest text the terminal sym matches at position i, or -1.
+func (p *earleyParser) match(sym string, i int) int {
+	if ends := p.matches(sym, i); len(ends) > 0 {
+		return ends[len(ends)-1]
+	}
+	return -1
+}
+
+func (p *earleyParser) isTerminal(sym string) bool {
+	return p.nodes[sym].GetType() == GrammarTerminal
+}
+
+func (p *earleyParser) add(j int, item earleyItem) {
+	p.grow(j)
+	if p.seen[j][item] {
+		return
+	}
+	p.seen[j][item] = true
+	p.sets[j] = append(p.sets[j], item)
+	if alt := p.alts[item.sym][item.alt]; item.dot < len(alt) {
+		p.waiting[j][alt[item.dot]] = append(p.waiting[j][alt[item.dot]], item)
+	}
+}
+
+// parse recognizes input as a derivation of start and returns its parse tree.
+func (p *earleyParser) parse(start string, input string) (*parseNode, error) {
+	if _, ok := p.nodes[start]; !ok {
+		return nil, fmt.Errorf("no such symbol %s", start)
+	}
+	if p.isTerminal(start) {
+		p.setInput(input)
+		if !slices.Contains(p.matches(start, 0), len(input)) {
+			return nil, ErrNoParse
+		}
+		return &parseNode{node: p.nodes[start], start: 0, end: len(input)}, nil
+	}
+	p.recognize(start, input)
+	if len(p.completed) <= len(input) {
+		return nil, ErrNoParse
+	}
+	for _, origin := range p.completed[len(input)][start] {
+		if origin == 0 {
+			p.building = make(map[string]bool)
+			if tree := p.build(start, 0, len(input)); tree != nil {
+				return tree, nil
+			}
+		}
+	}
+	return nil, ErrNoParse
+}
+
+// prefix returns the length of the longest prefix of input derived from sym, or -1.
+func (p *earleyParser) prefix(sym string, input string) int {
+	if p.isTerminal(sym) {
+		p.setInput(input)
+		return p.match(sym, 0)
+	}
+	p.recognize(sym, input)
+	for end := len(p.completed) - 1; end >= 0; end-- {
+		if slices.Contains(p.completed[end][sym], 0) {
+			return end
+		}
+	}
+	return -1
+}
+
+// recognize fills the Earley sets of input for the nonterminal start. A set is made once
+// an item reaches it, and the recognition stops at the last one: no prefix of input longer
+// than it derives from start, so the rest of the input is not read, e.g. after a token.
+func (p *earleyParser) recognize(start string, input string) {
+	p.setInput(input)
+	p.sets, p.seen, p.waiting, p.completed = nil, nil, nil, nil
+	p.grow(0)
+	for a := range p.alts[start] {
+		p.add(0, earleyItem{sym: start, alt: a, origin: 0})
+	}
+
+	for j := 0; j < len(p.sets); j++ {
+		for k := 0; k < len(p.sets[j]); k++ {
+			item := p.sets[j][k]
+			alt := p.alts[item.sym][item.alt]
+			if item.dot == len(alt) {
+				p.complete(j, item)
+				continue
+			}
+			next := alt[item.dot]
+			advanced := item
+			advanced.dot++
+			if p.isTerminal(next) {
+				for _, end := range p.matches(next, j) {
+					p.add(end, advanced)
+				}
+				continue
+			}
+			for a := range p.alts[next] {
+				p.add(j, earleyItem{sym: next, alt: a, origin: j})
+			}
+			if p.nullable[next] {
+				p.add(j, advanced)
+			}
+		}
+	}
+}
+
+// grow makes the Earley sets up to the position j.
+func (p *earleyParser) grow(j int) {
+	for len(p.sets) <= j {
+		p.sets = append(p.sets, nil)
+		p.seen = append(p.seen, make(map[earleyItem]bool))
+		p.waiting = append(p.waiting, make(map[string][]earleyItem))
+		p.completed = append(p.completed, make(map[string][]int))
+	}
+}
+
+func (p *earleyParser) complete(j int, item earleyItem) {
+	if !slices.Contains(p.completed[j][item.sym], item.origin) {
+		p.completed[j][item.sym] = append(p.completed[j][item.sym], item.origin)
+	}
+	waiting := p.waiting[item.origin][item.sym]
+	for k := 0; k < len(waiting); k++ {
+		advanced := waiting[k]
+		advanced.dot++
+		p.add(j, advanced)
+	}
+}
+
+// spans returns the possible starts of sym when it ends at end, not before start.
+func (p *earleyParser) spans(sym string, start, end int) []int {
+	res := make([]int, 0)
+	if p.isTerminal(sym) {
+		for s := end; s >= start; s-- {
+			if slices.Contains(p.matches(sym, s), end) {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	for _, s := range p.completed[end][sym] {
+		if s >= start {
+			res = append(res, s)
+		}
+	}
+	if p.nullable[sym] && start <= end {
+		res = append(res, end)
+	}
+	return res
+}
+
+// build extracts one parse tree of sym spanning input[start:end].
+func (p *earleyParser) build(sym string, start, end int) *parseNode {
+	node := &parseNode{node: p.nodes[sym], start: start, end: end}
+	if p.isTerminal(sym) {
+		return node
+	}
+	key := fmt.Sprintf("%s@%d:%d", sym, start, end)
+	if p.building[key] {
+		return nil
+	}
+	p.building[key] = true
+	defer delete(p.building, key)
+
+	for _, alt := range p.alts[sym] {
+		children, ok := p.buildSeq(alt, len(alt)-1, start, end)
+		if !ok {
+			continue
+		}
+		t := p.nodes[sym].GetType()
+		if (t == GrammarREP || t == GrammarPLUS) && len(children) > 0 && children[len(children)-1].node.GetID() == sym {
+			rest := children[len(children)-1]
+			node.children = append(children[:len(children)-1], rest.children...)
+			node.repeat = rest.repeat + 1
+		} else {
+			node.children = children
+			if len(alt) > 0 && (t == GrammarREP || t == GrammarPLUS) {
+				node.repeat = 1
+			}
+		}
+		return node
+	}
+	return nil
+}
+
+func (p *earleyParser) buildSeq(alt []string, k, start, end int) ([]*parseNode, bool) {
+	if k < 0 {
+		return []*parseNode{}, start == end
+	}
+	for _, s := range p.spans(alt[k], start, end) {
+		prefix, ok := p.buildSeq(alt, k-1, start, s)
+		if !ok {
+			continue
+		}
+		child := p.build(alt[k], s, end)
+		if child == nil {
+			continue
+		}
+		return append(prefix, child), true
+	}
+	return nil, false
+}
+
+// Parse parses the input as a derivation of the grammar node symbol, the text of every
+// terminal being kept as its value, see DerivationTree.Derivation. It fails with ErrNoParse.
+func (g *Grammar) Parse(symbol, input string) (*Derivation, error) {
+	tree, err := newEarleyParser(g).parse(symbol, input)
+	if err != nil {
+		return nil, err
+	}
+	var convert func(pn *parseNode, parent *TreeNode) *TreeNode
+	convert = func(pn *parseNode, parent *TreeNode) *TreeNode {
+		n := &TreeNode{Symbol: pn.node, Type: pn.node.GetType(), Content: pn.node.GetContent(), Parent: parent}
+		if n.Type == GrammarTerminal {
+			n.Leaf, n.Value = true, input[pn.start:pn.end]
+		}
+		for _, c := range pn.children {
+			n.Children = append(n.Children, convert(c, n))
+		}
+		return n
+	}
+	return (&DerivationTree{Root: convert(tree, nil)}).Derivation()
+}
+
+// Tokenize splits the input into the tokens of the grammar: at every position, the longest
+// text matched by a terminal or a lexical production, see Grammar.InferLexical. A character
+// no token starts with is a token alone. The input is read no further than the tokens, so
+// that the time is linear in its length, unless a token may span all of it.
+func (g *Grammar) Tokenize(input string) []string {
+	p := newEarleyParser(g)
+	symbols := make([]string, 0)
+	for id, n := range p.nodes {
+		if n.GetType() == GrammarTerminal || n.GetType() == GrammarProduction && n.IsLexical() {
+			symbols = append(symbols, id)
+		}
+	}
+	res := make([]string, 0)
+	for i := 0; i < len(input); {
+		end := 0
+		for _, sym := range symbols {
+			end = max(end, p.prefix(sym, input[i:]))
+		}
+		if end == 0 {
+			_, end = utf8.DecodeRuneInString(input[i:])
+		}
+		res = append(res, input[i:i+end])
+		i += end
+	}
+	return res
+}
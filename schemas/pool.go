@@ -0,0 +1,190 @@
+package schemas
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+type PoolOptions struct {
+	Workers int
+	Seed    int64
+	Ordered bool
+	Setup   func(*Context)
+}
+
+type PoolOption func(*PoolOptions)
+
+// WithWorkers sets the number of generator goroutines, runtime.NumCPU() by default.
+func WithWorkers(n int) PoolOption {
+	return func(o *PoolOptions) {
+		o.Workers = n
+	}
+}
+
+// WithSeed sets the base seed. The instance i is generated with the seed Seed+i,
+// so the same seed yields the same instances whatever the number of workers.
+func WithSeed(seed int64) PoolOption {
+	return func(o *PoolOptions) {
+		o.Seed = seed
+	}
+}
+
+// WithOrdered delivers the instances in the order of their index instead of as soon as they are generated.
+func WithOrdered(ordered bool) PoolOption {
+	return func(o *PoolOptions) {
+		o.Ordered = ordered
+	}
+}
+
+// WithSetup is called on every new Context before the generation, e.g. to set a Budget.
+func WithSetup(setup func(*Context)) PoolOption {
+	return func(o *PoolOptions) {
+		o.Setup = setup
+	}
+}
+
+// PoolResult is an instance generated by a Pool.
+type PoolResult struct {
+	Index  int
+	Worker int
+	Seed   int64
+	Ctx    *Context
+	Err    error
+}
+
+// Pool generates instances of a grammar with several goroutines, each with its own Context.
+// The grammar is only read, the handlers of the chain must be safe for concurrent use.
+// The chain is driven like a single generation: its first handler pops the symbol stack.
+type Pool struct {
+	grammar *Grammar
+	start   string
+	chain   *Chain
+	options PoolOptions
+}
+
+func NewPool(g *Grammar, start string, chain *Chain, opts ...PoolOption) *Pool {
+	options := PoolOptions{
+		Workers: runtime.NumCPU(),
+		Seed:    rand.Int63(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+	return &Pool{
+		grammar: g,
+		start:   start,
+		chain:   chain,
+		options: options,
+	}
+}
+
+// orderWindow bounds, per worker, the instances generated ahead of the next one to deliver in the ordered mode.
+const orderWindow = 4
+
+// Run generates n instances, or instances until ctx is cancelled if n <= 0.
+// The channel is closed once every instance is delivered or ctx is cancelled.
+// In the ordered mode, an instance slow to generate holds back the ones after it:
+// at most orderWindow instances per worker are generated ahead of it.
+func (p *Pool) Run(ctx context.Context, n int) <-chan *PoolResult {
+	jobs := make(chan int)
+	done := make(chan *PoolResult, p.options.Workers)
+	out := make(chan *PoolResult, p.options.Workers)
+	var window chan struct{} // a slot per instance dispatched and not delivered yet
+	if p.options.Ordered {
+		window = make(chan struct{}, p.options.Workers*orderWindow)
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := 0; n <= 0 || i < n; i++ {
+			if window != nil {
+				select {
+				case window <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < p.options.Workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range jobs {
+				select {
+				case done <- p.generate(ctx, worker, i):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	go func() {
+		defer close(out)
+		send := func(r *PoolResult) bool {
+			select {
+			case out <- r:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		pending := make(map[int]*PoolResult)
+		next := 0
+		for r := range done {
+			if !p.options.Ordered {
+				if !send(r) {
+					return
+				}
+				continue
+			}
+			pending[r.Index] = r
+			for r, ok := pending[next]; ok; r, ok = pending[next] {
+				delete(pending, next)
+				if !send(r) {
+					return
+				}
+				<-window
+				next++
+			}
+		}
+	}()
+	return out
+}
+
+func (p *Pool) generate(parent context.Context, worker int, index int) *PoolResult {
+	res := &PoolResult{
+		Index:  index,
+		Worker: worker,
+		Seed:   p.options.Seed + int64(index),
+	}
+	ctx, err := NewContext(p.grammar, p.start, parent, nil, nil)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	ctx.Rand = rand.New(rand.NewSource(res.Seed))
+	if p.options.Setup != nil {
+		p.options.Setup(ctx)
+	}
+	_, res.Err = Generate(ctx, p.chain)
+	res.Ctx = ctx
+	return res
+}
@@ -0,0 +1,124 @@
+package schemas_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func trace(ctx *schemas.Context) string {
+	ids := make([]string, 0)
+	for _, n := range ctx.SymbolStack.GetTrace() {
+		ids = append(ids, n.GetID())
+	}
+	return strings.Join(ids, " ")
+}
+
+func runPool(t *testing.T, workers int, ordered bool) []string {
+	g := createBinaryTreeGrammar()
+	g.BuildShortestNotation()
+	chain, err := schemas.CreateChain("pool", &driveHandler{routes: []schemas.Handler{
+		&schemas.CatHandler{}, &schemas.OrHandler{}, &schemas.IDHandler{},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := schemas.NewPool(g, "S", chain,
+		schemas.WithWorkers(workers),
+		schemas.WithSeed(42),
+		schemas.WithOrdered(ordered),
+		schemas.WithSetup(func(ctx *schemas.Context) {
+			ctx.Budget = schemas.Budget{MaxTokens: 20}
+		}),
+	)
+	res := make([]string, 100)
+	seen := make(map[int]bool)
+	next := 0
+	for r := range pool.Run(context.Background(), len(res)) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if ordered && r.Index != next {
+			t.Fatalf("got instance %d, want %d", r.Index, next)
+		}
+		next++
+		seen[r.Index] = true
+		res[r.Index] = trace(r.Ctx)
+	}
+	if len(seen) != len(res) {
+		t.Fatalf("got %d instances, want %d", len(seen), len(res))
+	}
+	return res
+}
+
+func TestPool(t *testing.T) {
+	sequential := runPool(t, 1, true)
+	for _, ordered := range []bool{true, false} {
+		parallel := runPool(t, 8, ordered)
+		for i := range sequential {
+			if parallel[i] != sequential[i] {
+				t.Errorf("instance %d differs with 8 workers, the seeds are not per instance", i)
+			}
+		}
+	}
+}
+
+func TestPoolCancel(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	chain, err := schemas.CreateChain("pool", &driveHandler{routes: []schemas.Handler{
+		&schemas.CatHandler{}, &schemas.OrHandler{}, &schemas.IDHandler{},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.BuildShortestNotation()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := schemas.NewPool(g, "S", chain, schemas.WithWorkers(4), schemas.WithSetup(func(ctx *schemas.Context) {
+		ctx.Budget = schemas.Budget{MaxTokens: 10}
+	}))
+	cnt := 0
+	for range pool.Run(ctx, 0) {
+		cnt++
+		if cnt == 10 {
+			cancel()
+		}
+	}
+	if cnt < 10 {
+		t.Errorf("got %d instances before cancelling", cnt)
+	}
+}
+
+func TestPoolOrderedWindow(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	chain, err := schemas.CreateChain("pool", &driveHandler{routes: []schemas.Handler{
+		&schemas.CatHandler{}, &schemas.OrHandler{}, &schemas.IDHandler{},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.BuildShortestNotation()
+	// the first instance stalls, the ones after it must not pile up waiting for it
+	release := make(chan struct{})
+	var started atomic.Int32
+	pool := schemas.NewPool(g, "S", chain, schemas.WithWorkers(2), schemas.WithOrdered(true), schemas.WithSetup(func(ctx *schemas.Context) {
+		if started.Add(1) == 1 {
+			<-release
+		}
+		ctx.Budget = schemas.Budget{MaxTokens: 10}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	out := pool.Run(ctx, 0)
+	time.Sleep(200 * time.Millisecond)
+	if n := started.Load(); n > 100 {
+		t.Errorf("%d instances started while the first one stalled", n)
+	}
+	close(release)
+	cancel()
+	for range out {
+	}
+}
@@ -0,0 +1,232 @@
+package schemas
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"regexp"
+	"sort"
+)
+
+const ProbabilityHandlerName = "probability_handler"
+
+// GetChoiceProb returns the probability of every alternative of an OR node, keyed by the ID of the alternative.
+func (g *Node) GetChoiceProb() map[string]float64 {
+	return g.internal.GetProperty(Prop).ChoiceProb
+}
+
+func (g *Node) SetChoiceProb(prob map[string]float64) {
+	p := g.internal.GetProperty(Prop)
+	p.ChoiceProb = prob
+	g.internal.SetProperty(Prop, p)
+}
+
+// GetRepeatProb returns the probability of every repetition count of a REP or PLUS node.
+func (g *Node) GetRepeatProb() map[int]float64 {
+	return g.internal.GetProperty(Prop).RepeatProb
+}
+
+func (g *Node) SetRepeatProb(prob map[int]float64) {
+	p := g.internal.GetProperty(Prop)
+	p.RepeatProb = prob
+	g.internal.SetProperty(Prop, p)
+}
+
+// Learn parses every sample of the corpus against the grammar, starting from the start symbol,
+// and sets the probabilities of the OR alternatives and repetition counts to the observed
+// frequencies. alpha is the additive smoothing, so that constructs absent from the corpus can
+// still be generated. Samples that cannot be parsed are skipped; the number of parsed samples is returned.
+func (g *Grammar) Learn(corpus []string, alpha float64) (int, error) {
+	choices := make(map[string]map[string]int)
+	repeats := make(map[string]map[int]int)
+	var record func(n *parseNode)
+	record = func(n *parseNode) {
+		id := n.node.GetID()
+		switch n.node.GetType() {
+		case GrammarOR:
+			if choices[id] == nil {
+				choices[id] = make(map[string]int)
+			}
+			choices[id][n.children[0].node.GetID()]++
+		case GrammarREP, GrammarPLUS:
+			if repeats[id] == nil {
+				repeats[id] = make(map[int]int)
+			}
+			repeats[id][n.repeat]++
+		}
+		for _, c := range n.children {
+			record(c)
+		}
+	}
+
+	p := newEarleyParser(g)
+	parsed := 0
+	var errs error
+	for i, sample := range corpus {
+		tree, err := p.parse(g.GetStartSym(), sample)
+		if err != nil {
+			slog.Warn("skip sample", "index", i, "error", err)
+			errs = errors.Join(errs, fmt.Errorf("sample %d: %w", i, err))
+			continue
+		}
+		record(tree)
+		parsed++
+	}
+	if parsed == 0 && len(corpus) != 0 {
+		return 0, errs
+	}
+
+	for _, v := range g.internal.GetAllVertices() {
+		n := &Node{internal: v}
+		switch n.GetType() {
+		case GrammarOR:
+			children := n.GetSymbols()
+			total := float64(0)
+			for _, c := range children {
+				total += float64(choices[n.GetID()][c.GetID()]) + alpha
+			}
+			if total == 0 {
+				continue
+			}
+			prob := make(map[string]float64)
+			for _, c := range children {
+				prob[c.GetID()] = (float64(choices[n.GetID()][c.GetID()]) + alpha) / total
+			}
+			n.SetChoiceProb(prob)
+		case GrammarREP, GrammarPLUS:
+			counts := repeats[n.GetID()]
+			if len(counts) == 0 {
+				continue
+			}
+			least, most := 0, 0
+			if n.GetType() == GrammarPLUS {
+				least = 1
+			}
+			for k := range counts {
+				most = max(most, k)
+			}
+			total := float64(0)
+			for k := least; k <= most; k++ {
+				total += float64(counts[k]) + alpha
+			}
+			prob := make(map[int]float64)
+			for k := least; k <= most; k++ {
+				prob[k] = (float64(counts[k]) + alpha) / total
+			}
+			n.SetRepeatProb(prob)
+		}
+	}
+	return parsed, nil
+}
+
+// ProbabilityHandler chooses OR alternatives and repetition counts according to the
+// probabilities attached to the grammar, e.g. by Grammar.Learn. Nodes without
+// probabilities fall back to the behaviour of OrHandler, RepHandler and PlusHandler.
+// Invert favours the rare constructs by sampling proportionally to the inverse probabilities.
+type ProbabilityHandler struct {
+	Invert bool
+}
+
+func (h *ProbabilityHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
+	children := ctx.CurrentNode.GetSymbols()
+	if len(children) == 0 {
+		chain.Next(ctx, cb)
+		return
+	}
+	switch ctx.CurrentNode.GetType() {
+	case GrammarOR:
+		if ctx.Shrinking() {
+			ctx.ResultBuffer = append(ctx.ResultBuffer, shortestSymbol(ctx.CurrentNode))
+			break
+		}
+		candidates := ctx.fitting(children)
+		if len(candidates) == 0 {
+			ctx.ResultBuffer = append(ctx.ResultBuffer, shortestSymbol(ctx.CurrentNode))
+			break
+		}
+		prob := ctx.CurrentNode.GetChoiceProb()
+		weights := make([]float64, len(candidates))
+		for i, c := range candidates {
+			weights[i] = prob[c.GetID()]
+		}
+		idx, ok := h.choose(ctx.Rand, weights)
+		if !ok {
+			idx = ctx.Rand.Intn(len(candidates))
+		}
+		ctx.ResultBuffer = append(ctx.ResultBuffer, candidates[idx])
+	case GrammarREP, GrammarPLUS:
+		cnt := 0
+		prob := ctx.CurrentNode.GetRepeatProb()
+		counts := make([]int, 0, len(prob))
+		for k := range prob {
+			counts = append(counts, k)
+		}
+		sort.Ints(counts)
+		weights := make([]float64, len(counts))
+		for i, k := range counts {
+			weights[i] = prob[k]
+		}
+		if idx, ok := h.choose(ctx.Rand, weights); ok {
+			cnt = counts[idx]
+		} else if ctx.CurrentNode.GetType() == GrammarPLUS {
+			cnt = ctx.Rand.Intn(10) + 1
+		} else if ctx.Rand.Intn(10) > 8 {
+			cnt = 1
+		}
+		least := 0
+		if ctx.CurrentNode.GetType() == GrammarPLUS {
+			least = 1
+		}
+		if ctx.Shrinking() {
+			cnt = least
+		}
+		for cnt > least && !ctx.Fits(repeatSymbols(children, cnt)...) {
+			cnt--
+		}
+		for j := 0; j < cnt; j++ {
+			for i := len(children) - 1; i >= 0; i-- {
+				ctx.ResultBuffer = append(ctx.ResultBuffer, &Node{internal: children[i].internal})
+			}
+		}
+	}
+	chain.Next(ctx, cb)
+}
+
+// choose returns an index with probability proportional to its weight, or to its inverse if Invert is set.
+func (h *ProbabilityHandler) choose(rnd *rand.Rand, weights []float64) (int, bool) {
+	total := float64(0)
+	for i, w := range weights {
+		if w <= 0 {
+			weights[i] = 0
+			continue
+		}
+		if h.Invert {
+			weights[i] = 1 / w
+		}
+		total += weights[i]
+	}
+	if total == 0 {
+		return 0, false
+	}
+	r := rnd.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return i, true
+		}
+		r -= w
+	}
+	return len(weights) - 1, true
+}
+
+func (h *ProbabilityHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *ProbabilityHandler) Name() string {
+	return ProbabilityHandlerName
+}
+
+func (h *ProbabilityHandler) Type() GrammarType {
+	return GrammarOR | GrammarREP | GrammarPLUS
+}
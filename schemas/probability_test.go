@@ -0,0 +1,86 @@
+package schemas_test
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func TestLearn(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	parsed, err := g.Learn([]string{"a", "aa", "ab"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != 2 {
+		t.Errorf("parsed %d samples, want 2", parsed)
+	}
+	prob := g.GetNode("S#0").GetChoiceProb()
+	if math.Abs(prob["S#1"]-0.75) > 1e-9 || math.Abs(prob["S#2"]-0.25) > 1e-9 {
+		t.Errorf("unexpected choice probabilities %v", prob)
+	}
+
+	if _, err := g.Learn([]string{"b"}, 0); err == nil {
+		t.Error("learning from a corpus that cannot be parsed should fail")
+	}
+}
+
+func TestLearnRepetitionAndSave(t *testing.T) {
+	g := createSequenceGrammar()
+	if _, err := g.Learn([]string{"a", "abb", "abbc"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]float64{0: 1.0 / 3, 1: 0, 2: 2.0 / 3}
+	check := func(g *schemas.Grammar) {
+		prob := g.GetNode("T#2").GetRepeatProb()
+		if len(prob) != len(want) {
+			t.Fatalf("unexpected repetition probabilities %v", prob)
+		}
+		for k, v := range want {
+			if math.Abs(prob[k]-v) > 1e-9 {
+				t.Errorf("probability of %d repetitions is %f, want %f", k, prob[k], v)
+			}
+		}
+	}
+	check(g)
+
+	file := filepath.Join(t.TempDir(), "grammar")
+	if err := g.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	check(schemas.NewGrammar(schemas.WithLoadFromFile(file)))
+}
+
+func TestProbabilityHandler(t *testing.T) {
+	g := createSequenceGrammar()
+	if _, err := g.Learn([]string{"a", "abb", "abb"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	empty := func(h *schemas.ProbabilityHandler) float64 {
+		chain, err := schemas.CreateChain("probability", &driveHandler{routes: []schemas.Handler{&schemas.CatHandler{}, h}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cnt := 0
+		for i := 0; i < 300; i++ {
+			res := generate(t, g, "T", chain).Result.GetResult(nil)
+			switch strings.Count(res, "b") {
+			case 0:
+				cnt++
+			case 2:
+			default:
+				t.Fatalf("%q repeats 'b' a number of times never seen in the corpus", res)
+			}
+		}
+		return float64(cnt) / 300
+	}
+	if got := empty(&schemas.ProbabilityHandler{}); got > 0.5 {
+		t.Errorf("the empty repetition is chosen %f of the time, want about 1/3", got)
+	}
+	if got := empty(&schemas.ProbabilityHandler{Invert: true}); got < 0.5 {
+		t.Errorf("the empty repetition is chosen %f of the time with inverted probabilities, want about 2/3", got)
+	}
+}
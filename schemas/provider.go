@@ -0,0 +1,331 @@
+package schemas
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lucasjones/reggen"
+)
+
+const ProviderHandlerName = "provider_handler"
+
+var ErrNoValue = errors.New("no value to provide")
+
+// TerminalProvider chooses the text of a terminal, or of a whole production, see ProviderHandler.
+type TerminalProvider interface {
+	Provide(ctx *Context, node *Node) (string, error)
+}
+
+// ProviderFunc is a TerminalProvider given by a function.
+type ProviderFunc func(ctx *Context, node *Node) (string, error)
+
+func (f ProviderFunc) Provide(ctx *Context, node *Node) (string, error) {
+	return f(ctx, node)
+}
+
+// RegexProvider generates a string matching Pattern, or the content of the terminal if Pattern is empty.
+// The generator of a pattern is built once, and seeded from the Context on every call.
+type RegexProvider struct {
+	Pattern string
+	Limit   int // largest repetition count of * and +, 10 by default
+
+	mu         sync.Mutex
+	generators map[string]*reggen.Generator // by pattern
+}
+
+func (p *RegexProvider) Provide(ctx *Context, node *Node) (string, error) {
+	pattern := p.Pattern
+	if pattern == "" {
+		pattern = strings.Trim(node.GetContent(), "'\"")
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	g, ok := p.generators[pattern]
+	if !ok {
+		var err error
+		if g, err = reggen.NewGenerator(pattern); err != nil {
+			return "", err
+		}
+		if p.generators == nil {
+			p.generators = make(map[string]*reggen.Generator)
+		}
+		p.generators[pattern] = g
+	}
+	g.SetSeed(ctx.Rand.Int63())
+	return g.Generate(limit), nil
+}
+
+// DictionaryProvider chooses one of Words, or of the lines of File.
+type DictionaryProvider struct {
+	Words []string
+	File  string
+
+	once  sync.Once
+	words []string
+	err   error
+}
+
+func (p *DictionaryProvider) load() {
+	p.words = append(p.words, p.Words...)
+	if p.File == "" {
+		return
+	}
+	f, err := os.Open(p.File)
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			p.words = append(p.words, line)
+		}
+	}
+	p.err = scanner.Err()
+}
+
+func (p *DictionaryProvider) Provide(ctx *Context, node *Node) (string, error) {
+	p.once.Do(p.load)
+	if p.err != nil {
+		return "", p.err
+	}
+	if len(p.words) == 0 {
+		return "", fmt.Errorf("%w: the dictionary is empty", ErrNoValue)
+	}
+	return p.words[ctx.Rand.Intn(len(p.words))], nil
+}
+
+// RangeProvider chooses an integer of [Min, Max]. With the probability Boundary, 0.2 by default
+// and none if negative, it chooses one of the boundary values: Min, Min+1, 0, Max-1 and Max.
+type RangeProvider struct {
+	Min      int64
+	Max      int64
+	Boundary float64
+}
+
+func (p *RangeProvider) Provide(ctx *Context, node *Node) (string, error) {
+	if p.Min > p.Max {
+		return "", fmt.Errorf("%w: the range [%d, %d] is empty", ErrNoValue, p.Min, p.Max)
+	}
+	boundary := p.Boundary
+	if boundary == 0 {
+		boundary = 0.2
+	}
+	if ctx.Rand.Float64() < boundary {
+		candidates := make([]int64, 0, 5)
+		for _, v := range []int64{p.Min, p.Min + 1, 0, p.Max - 1, p.Max} {
+			if v >= p.Min && v <= p.Max && !slices.Contains(candidates, v) {
+				candidates = append(candidates, v)
+			}
+		}
+		return fmt.Sprint(candidates[ctx.Rand.Intn(len(candidates))]), nil
+	}
+	// computed modulo 2^64, so that any range fits
+	span := uint64(p.Max) - uint64(p.Min) + 1
+	if span == 0 {
+		return fmt.Sprint(int64(ctx.Rand.Uint64())), nil
+	}
+	return fmt.Sprint(int64(uint64(p.Min) + ctx.Rand.Uint64()%span)), nil
+}
+
+// StorageProvider chooses one of the values recorded in the Storage under ID, see ProviderRule.Record.
+// It fails with a dead end if there is none yet, e.g. an identifier used before any is defined.
+type StorageProvider struct {
+	ID string
+}
+
+func (p *StorageProvider) Provide(ctx *Context, node *Node) (string, error) {
+	raw, err := ctx.Storage.Txn(false).First("nodeRuntimeInfo", "id", p.ID)
+	if err != nil {
+		return "", err
+	}
+	if raw == nil || len(raw.(*NodeRuntimeInfo).SampledValue) == 0 {
+		return "", fmt.Errorf("%w: nothing recorded under %s", ErrNoValue, p.ID)
+	}
+	values := make([]string, 0)
+	for v := range raw.(*NodeRuntimeInfo).SampledValue {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values[ctx.Rand.Intn(len(values))], nil
+}
+
+// record adds value to the values recorded in the Storage under id.
+func record(ctx *Context, id string, value string) error {
+	txn := ctx.Storage.Txn(true)
+	defer txn.Abort()
+	raw, err := txn.First("nodeRuntimeInfo", "id", id)
+	if err != nil {
+		return err
+	}
+	// the objects of the Storage are shared by its snapshots, they are never modified
+	info := &NodeRuntimeInfo{ID: id, SampledValue: make(map[string]int)}
+	if raw != nil {
+		old := raw.(*NodeRuntimeInfo)
+		info.Count = old.Count
+		info.SampledValue = maps.Clone(old.SampledValue)
+	}
+	info.Count++
+	info.SampledValue[value]++
+	if err := txn.Insert("nodeRuntimeInfo", info); err != nil {
+		return err
+	}
+	txn.Commit()
+	return nil
+}
+
+var providers = struct {
+	sync.RWMutex
+	types map[string]reflect.Type
+}{types: make(map[string]reflect.Type)}
+
+func init() {
+	RegisterProvider("regex", &RegexProvider{})
+	RegisterProvider("dictionary", &DictionaryProvider{})
+	RegisterProvider("range", &RangeProvider{})
+	RegisterProvider("storage", &StorageProvider{})
+}
+
+// RegisterProvider makes p available to the ProviderRule under name. p must be a pointer to a struct.
+func RegisterProvider(name string, p TerminalProvider) {
+	t := reflect.TypeOf(p)
+	if t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("the provider %s should be a pointer to a struct", name))
+	}
+	providers.Lock()
+	defer providers.Unlock()
+	providers.types[name] = t.Elem()
+}
+
+// NewProvider creates the provider registered under name, the parameters set its fields like Registry.New.
+func NewProvider(name string, params map[string]any) (TerminalProvider, error) {
+	providers.RLock()
+	t, ok := providers.types[name]
+	providers.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown terminal provider %s", name)
+	}
+	p := reflect.New(t).Interface().(TerminalProvider)
+	if err := decodeParams(p, params); err != nil {
+		return nil, fmt.Errorf("parameters of %s: %w", name, err)
+	}
+	return p, nil
+}
+
+// ProviderRule gives the provider of some terminals, or of the nodes of a production.
+// A production given by a provider is not expanded, its text is the value provided.
+type ProviderRule struct {
+	Terminal   string           // regular expression matching the ID or the unquoted content of the terminals
+	Production string           // name of the production
+	Provider   string           // name of a registered provider, see RegisterProvider
+	Params     map[string]any   // parameters of the provider
+	Use        TerminalProvider `json:"-"` // provider set in Go, instead of Provider
+	Record     string           // if set, the values are recorded in the Storage under this ID, see StorageProvider
+
+	re *regexp.Regexp
+}
+
+func (r *ProviderRule) match(n *Node) bool {
+	switch n.GetType() {
+	case GrammarTerminal:
+		return r.re != nil && (r.re.MatchString(n.GetID()) || r.re.MatchString(strings.Trim(n.GetContent(), "'\"")))
+	case GrammarID:
+		return r.Production != "" && n.GetContent() == r.Production
+	case GrammarProduction:
+		return r.Production != "" && n.GetID() == r.Production
+	}
+	return false
+}
+
+// ProviderHandler chooses the text of the terminals and records it in the Derivation,
+// so that GetResult writes the same text every time. The first rule matching a node
+// gives its provider; the other terminals are rendered as usual, quoted literals as they
+// are and double-quoted ones as regular expressions, generated once when they are popped.
+// Place it before the handlers expanding the nodes. The rules must not be changed once
+// the handler is used.
+type ProviderHandler struct {
+	Rules []ProviderRule
+
+	once sync.Once
+	err  error
+}
+
+func (h *ProviderHandler) init() {
+	for i := range h.Rules {
+		r := &h.Rules[i]
+		if r.Terminal != "" {
+			if r.re, h.err = regexp.Compile("^(" + r.Terminal + ")$"); h.err != nil {
+				return
+			}
+		}
+		if r.Use == nil {
+			if r.Use, h.err = NewProvider(r.Provider, r.Params); h.err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *ProviderHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
+	h.once.Do(h.init)
+	if h.err != nil {
+		ctx.Error = h.err
+		return
+	}
+	node := ctx.CurrentNode
+	var rule *ProviderRule
+	for i := range h.Rules {
+		if h.Rules[i].match(node) {
+			rule = &h.Rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		// the text of the other terminals is recorded when they are popped
+		chain.Next(ctx, cb)
+		return
+	}
+
+	value, err := rule.Use.Provide(ctx, node)
+	if err != nil {
+		ctx.Error = fmt.Errorf("%w: %s: %w", ErrDeadEnd, node.GetID(), err)
+		return
+	}
+	ctx.Result.setValue(node, value)
+	if rule.Record != "" {
+		if err := record(ctx, rule.Record, value); err != nil {
+			ctx.Error = err
+			return
+		}
+	}
+	if node.GetType() == GrammarTerminal {
+		chain.Next(ctx, cb)
+	}
+	// the production is not expanded, the rest of the chain is skipped
+}
+
+func (h *ProviderHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *ProviderHandler) Name() string {
+	return ProviderHandlerName
+}
+
+func (h *ProviderHandler) Type() GrammarType {
+	return GrammarTerminal | GrammarID | GrammarProduction
+}
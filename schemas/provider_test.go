@@ -0,0 +1,177 @@
+package schemas_test
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// createNumberGrammar N = "[0-9]{12}", M; M = 'm'
+func createNumberGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("N"))
+	n := schemas.NewNode(g, schemas.GrammarProduction, "N", `"[0-9]{12}", M`)
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "N#0", `"[0-9]{12}", M`)
+	n.AddSymbol(cat)
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "N#1", `"[0-9]{12}"`))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "N#2", "M"))
+	m := schemas.NewNode(g, schemas.GrammarProduction, "M", "'m'")
+	m.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "M#0", "'m'"))
+	return g
+}
+
+func generateWithProvider(t *testing.T, h schemas.Handler) *schemas.Context {
+	chain, err := schemas.CreateChain("provider", &schemas.BacktrackHandler{}, h, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, createNumberGrammar())
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+func TestProviderHandlerStable(t *testing.T) {
+	ctx := generateWithProvider(t, &schemas.ProviderHandler{})
+	res := ctx.Result.GetResult(nil)
+	if !regexp.MustCompile(`^[0-9]{12}m$`).MatchString(res) {
+		t.Fatalf("got %q", res)
+	}
+	if again := ctx.Result.GetResult(nil); again != res {
+		t.Errorf("the terminal was sampled again, got %q then %q", res, again)
+	}
+}
+
+func TestTerminalRecorded(t *testing.T) {
+	hooks := schemas.NewEdgeHooks()
+	whole := ""
+	if err := hooks.Post(schemas.EdgePattern{From: "N", To: "N#0"}, func(ctx *schemas.Context, from, to *schemas.Node, text string) {
+		whole = text
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// no handler of the chain records the terminal, it is recorded when it is popped
+	ctx := generateWithHooks(t, createNumberGrammar(), hooks)
+	res := ctx.Result.GetResult(nil)
+	if !regexp.MustCompile(`^[0-9]{12}m$`).MatchString(res) {
+		t.Fatalf("got %q", res)
+	}
+	if whole != res {
+		t.Errorf("the post hook got %q, the result is %q", whole, res)
+	}
+	if again := ctx.Result.GetResult(nil); again != res {
+		t.Errorf("the terminal was sampled again, got %q then %q", res, again)
+	}
+	if text := ctx.Result.Render(nil, nil); text != res {
+		t.Errorf("rendered %q, the result is %q", text, res)
+	}
+	if text := ctx.Result.Tree().Text; text != res {
+		t.Errorf("the tree has the text %q, the result is %q", text, res)
+	}
+
+	// the text of an identifier given by a provider is its value
+	h := &schemas.ProviderHandler{Rules: []schemas.ProviderRule{{Production: "M", Use: schemas.ProviderFunc(func(ctx *schemas.Context, node *schemas.Node) (string, error) {
+		return "VALUE", nil
+	})}}}
+	chain, err := schemas.CreateChain("provider", &schemas.BacktrackHandler{}, h, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks = schemas.NewEdgeHooks()
+	texts := make(map[string]string)
+	if err := hooks.Post(schemas.EdgePattern{}, func(ctx *schemas.Context, from, to *schemas.Node, text string) {
+		texts[to.GetID()] = text
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx = newContext(t, createNumberGrammar())
+	ctx.Hooks = hooks
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	res = ctx.Result.GetResult(nil)
+	if texts["N#2"] != "VALUE" || texts["N#0"] != res || texts["N#1"] != strings.TrimSuffix(res, "VALUE") {
+		t.Errorf("the post hooks got %v, the result is %q", texts, res)
+	}
+}
+
+func TestProviderRules(t *testing.T) {
+	h := &schemas.ProviderHandler{Rules: []schemas.ProviderRule{
+		{Terminal: "N#1", Use: &schemas.RangeProvider{Min: -3, Max: 3}},
+		{Production: "M", Record: "ms", Use: schemas.ProviderFunc(func(ctx *schemas.Context, node *schemas.Node) (string, error) {
+			return "fn", nil
+		})},
+	}}
+	ctx := generateWithProvider(t, h)
+	if res := ctx.Result.GetResult(nil); !regexp.MustCompile(`^-?[0-3]fn$`).MatchString(res) {
+		t.Errorf("got %q", res)
+	}
+	for _, n := range ctx.SymbolStack.GetTrace() {
+		if n.GetID() == "M#0" {
+			t.Error("the production given by a provider should not be expanded")
+		}
+	}
+	if v, err := (&schemas.StorageProvider{ID: "ms"}).Provide(ctx, nil); err != nil || v != "fn" {
+		t.Errorf("got %q, %v, want the value recorded in the storage", v, err)
+	}
+	if _, err := (&schemas.StorageProvider{ID: "none"}).Provide(ctx, nil); !errors.Is(err, schemas.ErrNoValue) {
+		t.Errorf("got error %v, want no value", err)
+	}
+
+	// the rules can be given as parameters, e.g. in a chain config
+	configured, err := schemas.DefaultRegistry.New(schemas.ProviderHandlerName, map[string]any{
+		"rules": []any{map[string]any{"production": "M", "provider": "dictionary", "params": map[string]any{"words": []string{"w"}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := generateWithProvider(t, configured).Result.GetResult(nil); !regexp.MustCompile(`^[0-9]{12}w$`).MatchString(res) {
+		t.Errorf("got %q", res)
+	}
+}
+
+func TestRegexProvider(t *testing.T) {
+	p := &schemas.RegexProvider{Pattern: "[a-z]{8}"}
+	ctx := newContext(t, createNumberGrammar())
+	seen := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		ctx.Rand = rand.New(rand.NewSource(int64(i % 2)))
+		v, err := p.Provide(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(`^[a-z]{8}$`).MatchString(v) {
+			t.Fatalf("got %q", v)
+		}
+		seen[v] = true
+	}
+	// the generator is built once, the values only depend on the random source of the Context
+	if len(seen) != 2 {
+		t.Errorf("got %d values from 2 seeds", len(seen))
+	}
+	if _, err := (&schemas.RegexProvider{Pattern: "["}).Provide(ctx, nil); err == nil {
+		t.Error("the pattern should not compile")
+	}
+}
+
+func TestRangeProvider(t *testing.T) {
+	ctx := newContext(t, createNumberGrammar())
+	for _, p := range []*schemas.RangeProvider{{Min: 5, Max: 5}, {Min: math.MinInt64, Max: math.MaxInt64}, {Min: -2, Max: 100, Boundary: -1}} {
+		for i := 0; i < 100; i++ {
+			v, err := p.Provide(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < p.Min || n > p.Max {
+				t.Fatalf("got %s out of [%d, %d]", v, p.Min, p.Max)
+			}
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package query
 
+import "slices"
+
 func reversePattern(pattern []string) {
 	for i, j := 0, len(pattern)-1; i < j; i, j = i+1, j-1 {
 		pattern[i], pattern[j] = pattern[j], pattern[i]
@@ -48,3 +50,19 @@ func matchPattern(pathParts []string, pattern []string) bool {
 
 	return match(len(pathParts)-1, 0)
 }
+
+// Pattern is a parsed PathQuery expression, see Compile.
+type Pattern []string
+
+// Compile parses pattern once so that it can be matched against many paths.
+func Compile(pattern string) Pattern {
+	if pattern == "" {
+		return nil
+	}
+	return Parse(pattern)
+}
+
+func (p Pattern) Match(path []string) bool {
+	// matchPattern reverses the pattern in place
+	return matchPattern(path, slices.Clone(p))
+}
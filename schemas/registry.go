@@ -0,0 +1,95 @@
+package schemas
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var ErrUnknownHandler = errors.New("unknown handler")
+
+// Registry creates handlers by their Name(), see LoadChain.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]reflect.Type
+}
+
+// DefaultRegistry holds the handlers of this package, other packages add theirs with Register.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	for _, h := range []Handler{
+		&CatHandler{}, &OrHandler{}, &IDHandler{}, &RepHandler{}, &TermHandler{}, &BracketHandler{},
+		&PlusHandler{}, &SubHandler{}, &TraceHandler{}, &OptionHandler{},
+		&ProbabilityHandler{}, &UniformSizeHandler{}, &BacktrackHandler{}, &ProviderHandler{},
+		&RecordHandler{}, &ReplayHandler{},
+	} {
+		DefaultRegistry.Register(h)
+	}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{types: make(map[string]reflect.Type)}
+}
+
+// Register adds a handler to the DefaultRegistry.
+func Register(h Handler) {
+	DefaultRegistry.Register(h)
+}
+
+// Register makes h available under h.Name(). h must be a pointer to a struct, a new one is created for every use.
+func (r *Registry) Register(h Handler) {
+	t := reflect.TypeOf(h)
+	if t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("the handler %s should be a pointer to a struct", h.Name()))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[h.Name()] = t.Elem()
+}
+
+// New creates the handler registered under name. The parameters set its exported
+// fields by name, case-insensitively, e.g. {"probability": 0.3} for RepHandler.
+func (r *Registry) New(name string, params map[string]any) (Handler, error) {
+	r.mu.RLock()
+	t, ok := r.types[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHandler, name)
+	}
+	h := reflect.New(t).Interface().(Handler)
+	if err := decodeParams(h, params); err != nil {
+		return nil, fmt.Errorf("parameters of %s: %w", name, err)
+	}
+	return h, nil
+}
+
+// decodeParams sets the exported fields of v by the names of the parameters, case-insensitively.
+func decodeParams(v any, params map[string]any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// Names lists the registered handlers.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make([]string, 0, len(r.types))
+	for name := range r.types {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,406 @@
+package schemas
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const StreamHandlerName = "stream_handler"
+
+var ErrOutputLimit = errors.New("output limit reached")
+
+// Layout joins the tokens of a derivation, see Derivation.Tokens and Derivation.Render. The zero Layout
+// concatenates them like GetResult, so the grammar has to give the whitespace itself.
+type Layout struct {
+	Separator   string                      `json:"separator" yaml:"separator"` // written between two tokens on a line
+	AutoSpace   bool                        `json:"autoSpace" yaml:"autoSpace"` // without Separator, write a space between two tokens that would read as one
+	Indent      string                      `json:"indent" yaml:"indent"`       // indentation of one level, "  " by default
+	Productions map[string]ProductionLayout `json:"productions" yaml:"productions"`
+	MaxBytes    int64                       `json:"maxBytes" yaml:"maxBytes"` // the output is cut after MaxBytes bytes with ErrOutputLimit, no limit if 0
+
+	NeedSpace func(prev, next string) bool `json:"-" yaml:"-"` // replaces the rule of AutoSpace
+}
+
+// ProductionLayout pretty-prints the text of a production on lines of its own.
+type ProductionLayout struct {
+	NewlineBefore bool `json:"newlineBefore" yaml:"newlineBefore"`
+	NewlineAfter  bool `json:"newlineAfter" yaml:"newlineAfter"`
+	Indent        bool `json:"indent" yaml:"indent"` // its lines are indented one more level
+}
+
+// needSpace is the rule of AutoSpace: two words, e.g. a keyword and an identifier,
+// or two operators, e.g. '+' and '+', must be told apart by a space.
+func needSpace(prev, next string) bool {
+	if prev == "" || next == "" {
+		return false
+	}
+	a := []rune(prev)[len([]rune(prev))-1]
+	b := []rune(next)[0]
+	if unicode.IsSpace(a) || unicode.IsSpace(b) {
+		return false
+	}
+	word := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	const operators = "+-*/%=<>!&|^~.:?"
+	return word(a) && word(b) || strings.ContainsRune(operators, a) && strings.ContainsRune(operators, b)
+}
+
+// layoutWriter writes the tokens as the Layout says. The text of a lexical production
+// is one token: the layout never goes between the characters of an identifier.
+type layoutWriter struct {
+	layout  *Layout
+	out     io.Writer
+	n       int64 // bytes written
+	err     error
+	prev    string // last token written
+	depth   int
+	newline bool // a newline is pending before the next token
+
+	lexical int             // depth of nested lexical productions
+	word    strings.Builder // text of the lexical production being written
+	onToken func(token string)
+}
+
+func (w *layoutWriter) write(s string) {
+	if w.err != nil {
+		return
+	}
+	if limit := w.layout.MaxBytes; limit > 0 && w.n+int64(len(s)) > limit {
+		// the text is cut before the rune the limit falls in
+		cut := int(limit - w.n)
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+		w.err = ErrOutputLimit
+	}
+	n, err := io.WriteString(w.out, s)
+	w.n += int64(n)
+	if err != nil {
+		w.err = err
+	}
+}
+
+func (w *layoutWriter) token(s string) {
+	if s == "" || w.err != nil {
+		return
+	}
+	if w.lexical > 0 {
+		w.word.WriteString(s)
+		return
+	}
+	if w.onToken != nil {
+		w.onToken(s)
+	}
+	l := w.layout
+	switch {
+	case w.prev == "":
+	case w.newline:
+		indent := l.Indent
+		if indent == "" {
+			indent = "  "
+		}
+		w.write("\n" + strings.Repeat(indent, w.depth))
+	case l.Separator != "":
+		w.write(l.Separator)
+	case l.NeedSpace != nil:
+		if l.NeedSpace(w.prev, s) {
+			w.write(" ")
+		}
+	case l.AutoSpace:
+		if needSpace(w.prev, s) {
+			w.write(" ")
+		}
+	}
+	w.newline = false
+	w.write(s)
+	w.prev = s
+}
+
+func (w *layoutWriter) enter(n *Node) {
+	p, ok := w.layout.Productions[trimNumber(n.GetID())]
+	if n.IsLexical() {
+		w.lexical++
+	}
+	if !ok {
+		return
+	}
+	if p.Indent {
+		w.depth++
+	}
+	if p.NewlineBefore {
+		w.newline = true
+	}
+}
+
+func (w *layoutWriter) leave(n *Node) {
+	if n.IsLexical() {
+		w.lexical--
+		if w.lexical == 0 {
+			word := w.word.String()
+			w.word.Reset()
+			w.token(word)
+		}
+	}
+	p, ok := w.layout.Productions[trimNumber(n.GetID())]
+	if !ok {
+		return
+	}
+	if p.Indent {
+		w.depth--
+	}
+	if p.NewlineAfter {
+		w.newline = true
+	}
+}
+
+// walk visits the derivation tree from node, calling enter before the children of a node and leave after them.
+func walk(node *Node, enter func(*Node) bool, leave func(*Node)) {
+	if node == nil {
+		return
+	}
+	if enter(node) {
+		for _, child := range node.GetSymbols() {
+			if child.GetID() == node.GetID() {
+				continue
+			}
+			walk(child, enter, leave)
+		}
+	}
+	leave(node)
+}
+
+// Render returns the text of the derivation with the layout, nil being the zero Layout.
+// Like GetResult, the values chosen by a ProviderHandler are written instead of what they derive.
+func (d *Derivation) Render(layout *Layout, custom func(content string) string) string {
+	out := &strings.Builder{}
+	_, _ = d.RenderTo(out, layout, custom)
+	return out.String()
+}
+
+// RenderTo is Render writing the text to out as the derivation is traversed.
+// It returns the number of bytes written, and ErrOutputLimit if the text is cut at layout.MaxBytes.
+func (d *Derivation) RenderTo(out io.Writer, layout *Layout, custom func(content string) string) (int64, error) {
+	if layout == nil {
+		layout = &Layout{}
+	}
+	w := &layoutWriter{layout: layout, out: out}
+	d.walkTokens(w, custom, nil)
+	return w.n, w.err
+}
+
+// walkTokens writes the tokens of the derivation with w, calling on, if not nil,
+// before a node is written and after it is.
+func (d *Derivation) walkTokens(w *layoutWriter, custom func(content string) string, on func(cur *Node, leave bool)) {
+	root := d.root()
+	if root == nil {
+		return
+	}
+	walk(root, func(cur *Node) bool {
+		if w.err != nil {
+			return false
+		}
+		if on != nil {
+			on(cur, false)
+		}
+		if cur.GetType() == GrammarProduction {
+			w.enter(cur)
+		}
+		if value, ok := d.values[cur.GetID()]; ok {
+			if custom != nil {
+				value = custom(value)
+			}
+			w.token(value)
+			return false
+		}
+		if cur.GetType() == GrammarTerminal {
+			content := renderTerminal(cur.GetContent())
+			if custom != nil {
+				content = custom(content)
+			}
+			w.token(content)
+		}
+		return true
+	}, func(cur *Node) {
+		if cur.GetType() == GrammarProduction {
+			w.leave(cur)
+		}
+		if on != nil {
+			on(cur, true)
+		}
+	})
+}
+
+// Tokens splits the text of the derivation into its tokens: the terminals, and the texts of
+// the lexical productions and of the values chosen by a ProviderHandler.
+func (d *Derivation) Tokens() []string {
+	res := make([]string, 0)
+	w := &layoutWriter{layout: &Layout{}, out: io.Discard, onToken: func(token string) {
+		res = append(res, token)
+	}}
+	d.walkTokens(w, nil, nil)
+	return res
+}
+
+// WriteTo writes the text of the derivation to out, see RenderTo.
+func (d *Derivation) WriteTo(out io.Writer) (int64, error) {
+	return d.RenderTo(out, nil, nil)
+}
+
+// StreamHandler writes the text to Writer while it is generated, as Render would with Layout.
+// It writes every token once all the symbols before it are expanded and stops at the first
+// symbol still on the stack, so a left-to-right expansion is written as it proceeds, and a
+// right-to-left one, like the handlers of this package, once its left part is complete.
+// The text of a terminal is recorded in the derivation as a ProviderHandler would, so that
+// GetResult writes the same text. Place it after the driver and before the ProviderHandler.
+// What is written cannot be taken back: do not use it with BacktrackHandler. If the text
+// exceeds Layout.MaxBytes, the generation fails with ErrOutputLimit.
+// What was written of a generation is kept in its Context, so the handler may be shared by
+// concurrent generations, e.g. of a Pool, which all write to Writer. A fork writes its text anew.
+type StreamHandler struct {
+	Writer io.Writer
+	Layout *Layout
+}
+
+// stream is what a StreamHandler wrote of a generation.
+type stream struct {
+	w    *layoutWriter
+	path []streamFrame // the nodes of the derivation being written
+	done bool
+}
+
+type streamFrame struct {
+	node     *Node
+	children []*Node
+	next     int // index of the first child not written
+}
+
+func (h *StreamHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
+	chain.Next(ctx, cb)
+	if ctx.Error != nil {
+		return
+	}
+	s := ctx.streams[h]
+	if s == nil {
+		layout := h.Layout
+		if layout == nil {
+			layout = &Layout{}
+		}
+		s = &stream{w: &layoutWriter{layout: layout, out: h.Writer}}
+		if ctx.streams == nil {
+			ctx.streams = make(map[*StreamHandler]*stream)
+		}
+		ctx.streams[h] = s
+	}
+	s.flush(ctx)
+	if s.w.err != nil {
+		ctx.Error = fmt.Errorf("writing %s: %w", ctx.CurrentNode.GetID(), s.w.err)
+	}
+}
+
+// flush writes the derivation from where it stopped up to the first symbol not expanded yet.
+func (s *stream) flush(ctx *Context) {
+	if s.done {
+		return
+	}
+	if s.path == nil {
+		root := ctx.Result.Grammar.GetNode(ctx.Result.Grammar.GetStartSym() + "#0")
+		if root == nil || !s.open(ctx, root) {
+			return
+		}
+	}
+	for len(s.path) > 0 && s.w.err == nil {
+		i := len(s.path) - 1
+		f := s.path[i]
+		if f.next == len(f.children) {
+			s.path = s.path[:i]
+			if f.node.GetType() == GrammarProduction {
+				s.w.leave(f.node)
+			}
+			continue
+		}
+		if !s.open(ctx, f.children[f.next]) {
+			return
+		}
+		s.path[i].next++
+	}
+	s.done = len(s.path) == 0
+}
+
+// open writes the node if its text is known, or enters its children if it is expanded.
+func (s *stream) open(ctx *Context, n *Node) bool {
+	if pending(ctx, n) {
+		return false
+	}
+	production := n.GetType() == GrammarProduction
+	if production {
+		s.w.enter(n)
+	}
+	value, ok := ctx.Result.values[n.GetID()]
+	if !ok && n.GetType() == GrammarTerminal {
+		var err error
+		if value, err = ctx.terminalValue(n.GetID(), n); err != nil {
+			s.w.err = err
+			return false
+		}
+		ok = true
+	}
+	if ok {
+		s.w.token(value)
+		if production {
+			s.w.leave(n)
+		}
+		return true
+	}
+	children := make([]*Node, 0)
+	for _, child := range n.GetSymbols() {
+		if child.GetID() != n.GetID() {
+			children = append(children, child)
+		}
+	}
+	s.path = append(s.path, streamFrame{node: n, children: children})
+	return true
+}
+
+// pending tells whether the derived node n is still on the stack, the current node
+// being done once the chain has handled it, unless it is to be expanded into some
+// children. A node expanded into none, e.g. an empty repetition, is done: nothing
+// may be flushed after it if it is the last one.
+func pending(ctx *Context, n *Node) bool {
+	sym := ctx.Result.nodes[n.GetID()]
+	if sym == nil || !ctx.SymbolStack.contains(sym) {
+		return false
+	}
+	if sym != ctx.CurrentNode {
+		return true
+	}
+	_, ok := ctx.Result.values[n.GetID()]
+	return !ok && n.GetType() != GrammarTerminal && len(ctx.ResultBuffer) > 0
+}
+
+// Written is the number of bytes written of the generation of ctx.
+func (h *StreamHandler) Written(ctx *Context) int64 {
+	if s := ctx.streams[h]; s != nil {
+		return s.w.n
+	}
+	return 0
+}
+
+func (h *StreamHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *StreamHandler) Name() string {
+	return StreamHandlerName
+}
+
+func (h *StreamHandler) Type() GrammarType {
+	return math.MaxInt
+}
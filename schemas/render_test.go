@@ -0,0 +1,209 @@
+package schemas_test
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// createStatementGrammar S = K, I, O, O; K = 'let'; I = 'x'; O = '+'
+func createStatementGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("S"))
+	s := schemas.NewNode(g, schemas.GrammarProduction, "S", "K, I, O, O")
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "S#0", "K, I, O, O")
+	s.AddSymbol(cat)
+	for i, id := range []string{"K", "I", "O", "O"} {
+		cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "S#"+string(rune('1'+i)), id))
+	}
+	for id, content := range map[string]string{"K": "'let'", "I": "'x'", "O": "'+'"} {
+		p := schemas.NewNode(g, schemas.GrammarProduction, id, content)
+		p.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, id+"#0", content))
+	}
+	return g
+}
+
+func TestRender(t *testing.T) {
+	chain, err := schemas.CreateChain("render", &schemas.BacktrackHandler{}, &schemas.ProviderHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, createStatementGrammar())
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		layout *schemas.Layout
+		want   string
+	}{
+		{nil, "letx++"},
+		{&schemas.Layout{AutoSpace: true}, "let x+ +"},
+		{&schemas.Layout{Separator: ","}, "let,x,+,+"},
+		{&schemas.Layout{AutoSpace: true, Productions: map[string]schemas.ProductionLayout{
+			"I": {NewlineBefore: true, NewlineAfter: true, Indent: true},
+		}}, "let\n  x\n+ +"},
+		{&schemas.Layout{NeedSpace: func(prev, next string) bool { return next == "+" }}, "letx + +"},
+	}
+	for _, c := range cases {
+		if got := ctx.Result.Render(c.layout, nil); got != c.want {
+			t.Errorf("got %q, want %q", got, c.want)
+		}
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	layout := &schemas.Layout{AutoSpace: true}
+	out := &strings.Builder{}
+	stream := &schemas.StreamHandler{Writer: out, Layout: layout}
+	chain, err := schemas.CreateChain("stream", &driveHandler{}, stream, &schemas.ProviderHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, createStatementGrammar())
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "let x+ +" || stream.Written(ctx) != int64(out.Len()) {
+		t.Errorf("streamed %q, %d bytes", out.String(), stream.Written(ctx))
+	}
+	if res := ctx.Result.Render(layout, nil); res != out.String() {
+		t.Errorf("rendered %q after streaming %q", res, out.String())
+	}
+
+	out.Reset()
+	n, err := ctx.Result.RenderTo(out, &schemas.Layout{AutoSpace: true, MaxBytes: 5}, nil)
+	if !errors.Is(err, schemas.ErrOutputLimit) || n != 5 || out.String() != "let x" {
+		t.Errorf("got %q, %d bytes, %v", out.String(), n, err)
+	}
+}
+
+func TestStreamHandlerLimit(t *testing.T) {
+	out := &strings.Builder{}
+	stream := &schemas.StreamHandler{Writer: out, Layout: &schemas.Layout{MaxBytes: 4}}
+	chain, err := schemas.CreateChain("stream", &driveHandler{}, stream, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, createStatementGrammar())
+	if _, err := schemas.Generate(ctx, chain); !errors.Is(err, schemas.ErrOutputLimit) {
+		t.Fatalf("got %v", err)
+	}
+	if out.String() != "letx" {
+		t.Errorf("got %q", out.String())
+	}
+}
+
+func TestStreamHandlerRuneLimit(t *testing.T) {
+	// the limit falls inside the separator after 'let', which is not written at all
+	out := &strings.Builder{}
+	stream := &schemas.StreamHandler{Writer: out, Layout: &schemas.Layout{Separator: "é", MaxBytes: 4}}
+	chain, err := schemas.CreateChain("stream", &driveHandler{}, stream, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, createStatementGrammar())
+	if _, err := schemas.Generate(ctx, chain); !errors.Is(err, schemas.ErrOutputLimit) {
+		t.Fatalf("got %v", err)
+	}
+	if out.String() != "let" || stream.Written(ctx) != 3 {
+		t.Errorf("got %q, %d bytes", out.String(), stream.Written(ctx))
+	}
+}
+
+func TestStreamHandlerShared(t *testing.T) {
+	out := &strings.Builder{}
+	stream := &schemas.StreamHandler{Writer: out}
+	chain, err := schemas.CreateChain("stream", &driveHandler{}, stream, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every generation keeps what it wrote in its Context
+	for i := 1; i <= 2; i++ {
+		ctx := newContext(t, createStatementGrammar())
+		if _, err := schemas.Generate(ctx, chain); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != strings.Repeat("letx++", i) || stream.Written(ctx) != 6 {
+			t.Errorf("got %q, %d bytes", out.String(), stream.Written(ctx))
+		}
+	}
+}
+
+// createEmptyRepetitionGrammar S = {'b'}, 'a'
+func createEmptyRepetitionGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("S"))
+	s := schemas.NewNode(g, schemas.GrammarProduction, "S", "{'b'}, 'a'")
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "S#0", "{'b'}, 'a'")
+	s.AddSymbol(cat)
+	rep := schemas.NewNode(g, schemas.GrammarREP, "S#1", "{'b'}")
+	cat.AddSymbol(rep)
+	rep.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "S#2", "'b'"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "S#3", "'a'"))
+	return g
+}
+
+func TestStreamHandlerEmptyExpansion(t *testing.T) {
+	for _, leftToRight := range []bool{false, true} {
+		out := &strings.Builder{}
+		stream := &schemas.StreamHandler{Writer: out}
+		chain, err := schemas.CreateChain("stream", &driveHandler{leftToRight: leftToRight}, stream, &schemas.CatHandler{}, &schemas.RepHandler{Probability: 1e-12})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := newContext(t, createEmptyRepetitionGrammar())
+		if _, err := schemas.Generate(ctx, chain); err != nil {
+			t.Fatal(err)
+		}
+		// the repetition expanded into nothing may be the last node, the text is written all the same
+		if res := ctx.Result.GetResult(nil); out.String() != res || res != "a" {
+			t.Errorf("left to right %v: streamed %q, the result is %q", leftToRight, out.String(), res)
+		}
+	}
+}
+
+// writtenHandler records the length of the output after every node.
+type writtenHandler struct {
+	out     *strings.Builder
+	written []int
+}
+
+func (h *writtenHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	chain.Next(ctx, cb)
+	h.written = append(h.written, h.out.Len())
+}
+
+func (h *writtenHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *writtenHandler) Name() string {
+	return "written"
+}
+
+func (h *writtenHandler) Type() schemas.GrammarType {
+	return math.MaxInt
+}
+
+func TestStreamHandlerLeftToRight(t *testing.T) {
+	out := &strings.Builder{}
+	probe := &writtenHandler{out: out}
+	stream := &schemas.StreamHandler{Writer: out, Layout: &schemas.Layout{Separator: " "}}
+	chain, err := schemas.CreateChain("stream", &driveHandler{leftToRight: true}, probe, stream, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, createStatementGrammar())
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "let x + +" {
+		t.Fatalf("got %q", out.String())
+	}
+	// the terminals are written as they are generated: 'let' is the 5th node, after S, its catenation, the ID K and K
+	if probe.written[4] != len("let") {
+		t.Errorf("written %v", probe.written)
+	}
+}
@@ -0,0 +1,253 @@
+package schemas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+)
+
+const (
+	RecordHandlerName = "record_handler"
+	ReplayHandlerName = "replay_handler"
+)
+
+var ErrReplayDiverged = errors.New("the replay diverges from the recorded choices")
+
+// Choice is a choice made during the generation: the alternative of an OR node, the
+// repetition count of a REP, PLUS, Optional or EXT node, or the text of a terminal or
+// of a production given by a ProviderHandler. The type of the node tells which.
+type Choice struct {
+	Symbol  string `json:"s"`           // ID of the grammar node
+	N       int    `json:"n,omitempty"` // index of the alternative in source order, or repetition count
+	Content string `json:"c,omitempty"` // content of the alternative, to find it again in an edited grammar
+	Value   string `json:"v,omitempty"` // text of the node
+}
+
+// Choices is the sequence of choices of a generation, in the order they were made.
+// It is enough to generate the input again, see ReplayHandler.
+type Choices []Choice
+
+// String encodes the choices in JSON.
+func (c Choices) String() string {
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+// ParseChoices decodes choices encoded by Choices.String.
+func ParseChoices(data string) (Choices, error) {
+	res := make(Choices, 0)
+	err := json.Unmarshal([]byte(data), &res)
+	return res, err
+}
+
+// Choices returns the choices recorded during the generation, see RecordHandler.
+func (c *Context) Choices() Choices {
+	return slices.Clone(c.recorded)
+}
+
+// recordChoice records the expansion chosen for the choice node n.
+func (c *Context) recordChoice(n *Node, expansion []*Node) {
+	ch := Choice{Symbol: n.GetID()}
+	children := sourceOrder(n.GetSymbols())
+	switch {
+	case n.GetType() == GrammarOR:
+		if len(expansion) != 1 {
+			return
+		}
+		ch.N = slices.IndexFunc(children, func(alt *Node) bool {
+			return alt.GetID() == expansion[0].GetID()
+		})
+		ch.Content = expansion[0].GetContent()
+	case len(children) > 0:
+		ch.N = len(expansion) / len(children)
+	}
+	c.recorded = append(c.recorded, ch)
+}
+
+// RecordHandler records the choices of the generation in the Context, see Context.Choices.
+// Place it right after the driver. The text of the terminals given as regular expressions,
+// and of the nodes given by a ProviderHandler, is recorded too.
+type RecordHandler struct{}
+
+func (h *RecordHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
+	ctx.recording = true
+	chain.Next(ctx, cb)
+	if ctx.Error != nil {
+		return
+	}
+	node := ctx.CurrentNode
+	if node.GetType()&choiceTypes != 0 {
+		ctx.recordChoice(node, ctx.ResultBuffer)
+		return
+	}
+	value, ok := ctx.Result.values[ctx.Result.idOf(node)]
+	if !ok && node.GetType() == GrammarTerminal && isRegexTerminal(node.GetContent()) {
+		// generated now rather than when it is popped, to be recorded in order
+		var err error
+		if value, err = ctx.terminalValue(ctx.Result.idOf(node), node); err != nil {
+			ctx.Error = fmt.Errorf("%s: %w", node.GetID(), err)
+			return
+		}
+		ok = true
+	}
+	if ok {
+		ctx.recorded = append(ctx.recorded, Choice{Symbol: node.GetID(), Value: value})
+	}
+}
+
+func (h *RecordHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *RecordHandler) Name() string {
+	return RecordHandlerName
+}
+
+func (h *RecordHandler) Type() GrammarType {
+	return math.MaxInt
+}
+
+// Divergence is a point where the grammar does not follow the recorded choices.
+type Divergence struct {
+	Position int    // index of the choice in the Choices, len(Choices) if they are exhausted
+	Symbol   string // grammar node being expanded
+	Reason   string
+}
+
+func (d Divergence) Error() string {
+	return fmt.Sprintf("choice %d at %s: %s", d.Position, d.Symbol, d.Reason)
+}
+
+// ReplayHandler makes the choices of a recorded generation again, so that the same input is
+// generated, see RecordHandler. Place it right after the driver: the nodes it chooses for are
+// not passed to the rest of the chain, which handles the others. If the grammar was edited,
+// an alternative is found again by its content, and the replay goes on after a divergence:
+// choices of nodes that are no longer expanded are skipped, and the rest of the chain chooses
+// for nodes without a recorded choice. The divergences are kept, see Divergences, or fail the
+// generation with ErrReplayDiverged if Strict. The position of the replay is kept in the
+// Context, so that a ReplayHandler replays the choices in any number of generations.
+type ReplayHandler struct {
+	Choices Choices
+	Strict  bool
+}
+
+// replay is the state of a ReplayHandler in the generation of a Context.
+type replay struct {
+	pos         int // index of the next choice to replay
+	divergences []Divergence
+}
+
+func (h *ReplayHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
+	r := ctx.replays[h]
+	if r == nil {
+		r = &replay{}
+		if ctx.replays == nil {
+			ctx.replays = make(map[*ReplayHandler]*replay)
+		}
+		ctx.replays[h] = r
+	}
+	node := ctx.CurrentNode
+	choice := node.GetType()&choiceTypes != 0
+	at := h.find(r, node.GetID(), choice)
+	if at < 0 {
+		if choice && !h.diverge(ctx, r, r.pos, node, "no choice recorded") {
+			return
+		}
+		chain.Next(ctx, cb)
+		return
+	}
+	if at > r.pos && !h.diverge(ctx, r, r.pos, node, fmt.Sprintf("the choices %d to %d are skipped", r.pos, at-1)) {
+		return
+	}
+	pos := r.pos
+	r.pos = at + 1
+	ctx.onRollback(func() { r.pos = pos })
+	c := h.Choices[at]
+	if !choice {
+		ctx.Result.setValue(node, c.Value)
+		return
+	}
+	expansion, reason := replayChoice(node, c)
+	if reason != "" {
+		if h.diverge(ctx, r, at, node, reason) {
+			chain.Next(ctx, cb)
+		}
+		return
+	}
+	ctx.ResultBuffer = append(ctx.ResultBuffer, expansion...)
+}
+
+// Divergences returns the divergences of the replay in the generation of ctx.
+func (h *ReplayHandler) Divergences(ctx *Context) []Divergence {
+	if r := ctx.replays[h]; r != nil {
+		return slices.Clone(r.divergences)
+	}
+	return nil
+}
+
+// find returns the position of the next choice of symbol. A choice node takes the
+// next one of its symbol, other nodes only the next choice, as they may have none.
+func (h *ReplayHandler) find(r *replay, symbol string, choice bool) int {
+	for i := r.pos; i < len(h.Choices); i++ {
+		if h.Choices[i].Symbol == symbol {
+			return i
+		}
+		if !choice {
+			break
+		}
+	}
+	return -1
+}
+
+// diverge records a divergence, and fails the generation if Strict. It returns whether the generation goes on.
+func (h *ReplayHandler) diverge(ctx *Context, r *replay, pos int, node *Node, reason string) bool {
+	d := Divergence{Position: pos, Symbol: node.GetID(), Reason: reason}
+	r.divergences = append(r.divergences, d)
+	ctx.onRollback(func() { r.divergences = r.divergences[:len(r.divergences)-1] })
+	if h.Strict {
+		ctx.Error = fmt.Errorf("%w: %w", ErrReplayDiverged, d)
+		return false
+	}
+	return true
+}
+
+// replayChoice returns the expansion of the choice node n recorded in c, or why it cannot be made.
+func replayChoice(n *Node, c Choice) ([]*Node, string) {
+	children := sourceOrder(n.GetSymbols())
+	switch n.GetType() {
+	case GrammarOR:
+		if c.N >= 0 && c.N < len(children) && children[c.N].GetContent() == c.Content {
+			return []*Node{children[c.N]}, ""
+		}
+		for _, alt := range children {
+			if alt.GetContent() == c.Content {
+				return []*Node{alt}, ""
+			}
+		}
+		return nil, fmt.Sprintf("the alternative %s is gone", c.Content)
+	case GrammarOptional, GrammarEXT:
+		if c.N > 1 {
+			return nil, fmt.Sprintf("%d repetitions of an optional node", c.N)
+		}
+	case GrammarPLUS:
+		if c.N < 1 {
+			return nil, "no repetition of a PLUS node"
+		}
+	}
+	return repeatSymbols(children, c.N), ""
+}
+
+func (h *ReplayHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *ReplayHandler) Name() string {
+	return ReplayHandlerName
+}
+
+func (h *ReplayHandler) Type() GrammarType {
+	return math.MaxInt
+}
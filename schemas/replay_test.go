@@ -0,0 +1,111 @@
+package schemas_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// createSwappedPairGrammar is createPairGrammar with the alternatives of E in another order.
+func createSwappedPairGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("E"))
+	e := schemas.NewNode(g, schemas.GrammarProduction, "E", "('<', E, E, '>') | T")
+	or := schemas.NewNode(g, schemas.GrammarOR, "E#0", "('<', E, E, '>') | T")
+	e.AddSymbol(or)
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "E#1", "'<', E, E, '>'")
+	or.AddSymbol(cat)
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "E#2", "'<'"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "E#3", "E"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "E#4", "E"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "E#5", "'>'"))
+	or.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "E#6", "T"))
+	tp := schemas.NewNode(g, schemas.GrammarProduction, "T", "'x'")
+	tp.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "T#0", "'x'"))
+	return g
+}
+
+func record(t *testing.T, g *schemas.Grammar) (string, schemas.Choices) {
+	chain, err := schemas.CreateChain("record", &driveHandler{}, &schemas.RecordHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.OrHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, g)
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	return ctx.Result.GetResult(nil), ctx.Choices()
+}
+
+func replay(t *testing.T, g *schemas.Grammar, h *schemas.ReplayHandler) (string, error) {
+	ctx, err := replayContext(t, g, h)
+	return ctx.Result.GetResult(nil), err
+}
+
+func replayContext(t *testing.T, g *schemas.Grammar, h *schemas.ReplayHandler) (*schemas.Context, error) {
+	chain, err := schemas.CreateChain("replay", &driveHandler{}, h, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.OrHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, g)
+	_, err = schemas.Generate(ctx, chain)
+	return ctx, err
+}
+
+func TestReplay(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		text, choices := record(t, createPairGrammar())
+		parsed, err := schemas.ParseChoices(choices.String())
+		if err != nil || len(parsed) != len(choices) {
+			t.Fatalf("got %v, %v", parsed, err)
+		}
+		// the handler replays the choices in every generation
+		h := &schemas.ReplayHandler{Choices: parsed, Strict: true}
+		for _, g := range []*schemas.Grammar{createPairGrammar(), createSwappedPairGrammar(), createPairGrammar()} {
+			got, err := replay(t, g, h)
+			if err != nil || got != text {
+				t.Fatalf("replayed %q, %v, want %q", got, err, text)
+			}
+		}
+	}
+}
+
+func TestReplayTerminal(t *testing.T) {
+	chain, err := schemas.CreateChain("record", &driveHandler{}, &schemas.RecordHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, createNumberGrammar())
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	text := ctx.Result.GetResult(nil)
+	got, err := replay(t, createNumberGrammar(), &schemas.ReplayHandler{Choices: ctx.Choices(), Strict: true})
+	if err != nil || got != text {
+		t.Fatalf("replayed %q, %v, want %q", got, err, text)
+	}
+}
+
+func TestReplayDiverged(t *testing.T) {
+	_, choices := record(t, createPairGrammar())
+	edited := func() *schemas.Grammar {
+		g := createPairGrammar()
+		g.GetNode("E#1").SetContent("U")
+		g.GetNode("E#7").SetContent("U")
+		return g
+	}
+
+	_, err := replay(t, edited(), &schemas.ReplayHandler{Choices: choices, Strict: true})
+	var d schemas.Divergence
+	if !errors.Is(err, schemas.ErrReplayDiverged) || !errors.As(err, &d) || d.Symbol != "E#0" {
+		t.Fatalf("got %v", err)
+	}
+
+	h := &schemas.ReplayHandler{Choices: choices}
+	for i := 0; i < 2; i++ {
+		ctx, _ := replayContext(t, edited(), h)
+		if got := h.Divergences(ctx); len(got) == 0 || got[0].Position != d.Position {
+			t.Fatalf("got %v", got)
+		}
+	}
+}
@@ -18,6 +18,11 @@ func NewResult(ctx *Context) *Result {
 func (r *Result) GetCtx() *Context {
 	return r.ctx
 }
+
+// GetError returns the error that stopped the generation, nil if it completed.
+func (r *Result) GetError() error {
+	return r.ctx.Error
+}
 func (r *Result) AddNode(n *Grammar) *Result {
 	r.path = append(r.path, n)
 	return r
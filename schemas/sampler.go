@@ -0,0 +1,426 @@
+package schemas
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const UniformSizeHandlerName = "uniform_size_handler"
+
+// UniformSizeHandler draws derivation trees uniformly at random among all trees
+// whose size lies in [MinSize, MaxSize]. The size of a tree is the number of
+// terminals it yields. The handler is counting based: for every grammar node it
+// counts the number of derivations of each size, then splits the size of a node
+// among its children proportionally to those counts.
+//
+// The handler expands every grammar type by itself, so it replaces CatHandler,
+// OrHandler, RepHandler, etc. in a chain instead of being added next to them.
+// SUB is approximated by its first operand, since exceptions cannot be counted.
+//
+// The sizes are bounded by the MaxTokens and the MaxExpansions of the Budget too.
+// The size does not tell the depth nor the expansions of a tree, though: a tree
+// beyond the Budget fails the generation with ErrBudgetExceeded, which Generate retries.
+type UniformSizeHandler struct {
+	MinSize int
+	MaxSize int
+
+	mu     sync.Mutex
+	tables map[*Grammar]*sizeTable
+}
+
+func (h *UniformSizeHandler) Handle(chain *Chain, ctx *Context, cb ResponseCallBack) {
+	cur := ctx.CurrentNode
+	if ctx.sizePlan == nil {
+		ctx.sizePlan = make(map[*Node]int)
+	}
+	table := h.table(ctx.Grammar)
+
+	n, ok := ctx.sizePlan[cur]
+	if !ok {
+		// the sizes of the descendants are planned within this one, so only unplanned nodes are bounded
+		hi, left := h.MaxSize, ctx.sizeLeft()
+		if left >= 0 {
+			hi = min(hi, left)
+		}
+		n, ok = table.pickSize(ctx.Rand, cur, h.MinSize, hi)
+		if !ok {
+			ctx.Error = fmt.Errorf("%w: no derivation of %s within the size range [%d, %d]", ErrDeadEnd, cur.GetID(), h.MinSize, h.MaxSize)
+			return
+		}
+		if left >= 0 && n > left {
+			ctx.Error = fmt.Errorf("%w: no derivation of %s within %d terminals", ErrBudgetExceeded, cur.GetID(), left)
+			return
+		}
+	}
+	ctx.plan(cur, -1)
+
+	children := table.sample(ctx.Rand, cur, n)
+	for i := len(children) - 1; i >= 0; i-- {
+		ctx.plan(children[i].node, children[i].size)
+		ctx.ResultBuffer = append(ctx.ResultBuffer, children[i].node)
+	}
+	chain.Next(ctx, cb)
+}
+
+// plan sets the target size of the pending node n, or removes it if size is negative.
+func (c *Context) plan(n *Node, size int) {
+	old, ok := c.sizePlan[n]
+	if size < 0 {
+		delete(c.sizePlan, n)
+	} else {
+		c.sizePlan[n] = size
+	}
+	c.onRollback(func() {
+		if ok {
+			c.sizePlan[n] = old
+		} else {
+			delete(c.sizePlan, n)
+		}
+	})
+}
+
+func (h *UniformSizeHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *UniformSizeHandler) Name() string {
+	return UniformSizeHandlerName
+}
+
+func (h *UniformSizeHandler) Type() GrammarType {
+	return GrammarProduction | GrammarOR | GrammarCatenate | GrammarOptional | GrammarREP | GrammarPLUS |
+		GrammarEXT | GrammarSUB | GrammarID | GrammarTerminal | GrammarChoice
+}
+
+// Count returns the number of derivations of the node id that yield exactly size terminals.
+func (h *UniformSizeHandler) Count(g *Grammar, id string, size int) *big.Int {
+	node := g.GetNode(id)
+	if node == nil || size < 0 || size > h.MaxSize {
+		return big.NewInt(0)
+	}
+	t := h.table(g)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return new(big.Int).Set(t.count(node, size))
+}
+
+func (h *UniformSizeHandler) table(g *Grammar) *sizeTable {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tables == nil {
+		h.tables = make(map[*Grammar]*sizeTable)
+	}
+	t, ok := h.tables[g]
+	if !ok {
+		t = newSizeTable(g, h.MaxSize)
+		h.tables[g] = t
+	}
+	return t
+}
+
+type sizedNode struct {
+	node *Node
+	size int
+}
+
+// sizeTable holds the derivation counts of one grammar up to a maximum size.
+type sizeTable struct {
+	mu     sync.Mutex
+	g      *Grammar
+	max    int
+	counts map[string][]*big.Int
+	// seqs[id][i][n] counts the ways children[i:] of node id yield n terminals when concatenated
+	seqs map[string][][]*big.Int
+	// reps[id][n] counts the repetitions of the children of node id yielding n terminals
+	reps map[string][]*big.Int
+}
+
+var bigZero = big.NewInt(0)
+
+func newSizeTable(g *Grammar, max int) *sizeTable {
+	t := &sizeTable{
+		g:      g,
+		max:    max,
+		counts: make(map[string][]*big.Int),
+		seqs:   make(map[string][][]*big.Int),
+		reps:   make(map[string][]*big.Int),
+	}
+	vertices := g.internal.GetAllVertices()
+	sort.Slice(vertices, func(i, j int) bool {
+		return vertices[i].GetID() > vertices[j].GetID()
+	})
+	nodes := make([]*Node, len(vertices))
+	for i, v := range vertices {
+		nodes[i] = &Node{internal: v}
+		t.counts[v.GetID()] = make([]*big.Int, max+1)
+		t.reps[v.GetID()] = make([]*big.Int, max+1)
+		seq := make([][]*big.Int, len(nodes[i].GetSymbols())+1)
+		for j := range seq {
+			seq[j] = make([]*big.Int, max+1)
+		}
+		t.seqs[v.GetID()] = seq
+	}
+
+	// Bellman-ford-like process, size by size: counts of smaller sizes are final,
+	// counts of the current size may depend on each other through unit productions
+	// and nullable symbols, so they are relaxed until nothing changes. A cycle that
+	// does not consume any terminal would yield infinitely many trees, so the
+	// number of rounds is bounded.
+	for n := 0; n <= max; n++ {
+		for _, node := range nodes {
+			t.counts[node.GetID()][n] = bigZero
+		}
+		for round := 0; round <= len(nodes); round++ {
+			stop := true
+			for _, node := range nodes {
+				res := t.compute(node, n)
+				if res.Cmp(t.counts[node.GetID()][n]) != 0 {
+					t.counts[node.GetID()][n] = res
+					stop = false
+				}
+			}
+			if stop {
+				break
+			}
+		}
+	}
+	return t
+}
+
+// count returns the number of derivations of node yielding n terminals.
+func (t *sizeTable) count(node *Node, n int) *big.Int {
+	if n < 0 || n > t.max {
+		return bigZero
+	}
+	row, ok := t.counts[node.GetID()]
+	if !ok || row[n] == nil {
+		return bigZero
+	}
+	return row[n]
+}
+
+func (t *sizeTable) compute(node *Node, n int) *big.Int {
+	children := node.GetSymbols()
+	switch node.GetType() {
+	case GrammarTerminal:
+		if n == 1 {
+			return big.NewInt(1)
+		}
+		return bigZero
+	case GrammarID:
+		prod := t.g.GetNode(node.GetContent())
+		if prod == nil {
+			return bigZero
+		}
+		return t.count(prod, n)
+	case GrammarOR:
+		res := new(big.Int)
+		for _, c := range children {
+			res.Add(res, t.count(c, n))
+		}
+		return res
+	case GrammarOptional, GrammarEXT:
+		res := new(big.Int).Set(t.computeSeq(node, children, n))
+		if n == 0 {
+			res.Add(res, big.NewInt(1))
+		}
+		return res
+	case GrammarREP:
+		t.computeSeq(node, children, n)
+		return t.computeRep(node, children, n)
+	case GrammarPLUS:
+		t.computeSeq(node, children, n)
+		t.computeRep(node, children, n)
+		return t.plus(node, n)
+	case GrammarSUB:
+		if len(children) == 0 {
+			return bigZero
+		}
+		return t.count(children[0], n)
+	default:
+		return t.computeSeq(node, children, n)
+	}
+}
+
+// computeSeq refreshes the concatenation counts of size n of node and returns seq(node, 0, n).
+func (t *sizeTable) computeSeq(node *Node, children []*Node, n int) *big.Int {
+	seq := t.seqs[node.GetID()]
+	seq[len(children)][n] = bigZero
+	if n == 0 {
+		seq[len(children)][n] = big.NewInt(1)
+	}
+	tmp := new(big.Int)
+	for i := len(children) - 1; i >= 0; i-- {
+		res := new(big.Int)
+		for m := 0; m <= n; m++ {
+			head := t.count(children[i], m)
+			if head.Sign() == 0 {
+				continue
+			}
+			res.Add(res, tmp.Mul(head, seq[i+1][n-m]))
+		}
+		seq[i][n] = res
+	}
+	return seq[0][n]
+}
+
+// computeRep refreshes the count of sequences of non-empty items yielding n
+// terminals, each item being the concatenation of the children of node.
+func (t *sizeTable) computeRep(node *Node, children []*Node, n int) *big.Int {
+	res := big.NewInt(1)
+	if n > 0 {
+		res = t.plus(node, n)
+	}
+	t.reps[node.GetID()][n] = res
+	return res
+}
+
+func (t *sizeTable) seq(node *Node, i, n int) *big.Int {
+	if n < 0 || n > t.max {
+		return bigZero
+	}
+	return t.seqs[node.GetID()][i][n]
+}
+
+func (t *sizeTable) rep(node *Node, n int) *big.Int {
+	if n < 0 || n > t.max {
+		return bigZero
+	}
+	return t.reps[node.GetID()][n]
+}
+
+func (t *sizeTable) plus(node *Node, n int) *big.Int {
+	res := new(big.Int)
+	tmp := new(big.Int)
+	for m := 1; m <= n; m++ {
+		item := t.seq(node, 0, m)
+		if item.Sign() == 0 {
+			continue
+		}
+		res.Add(res, tmp.Mul(item, t.rep(node, n-m)))
+	}
+	return res
+}
+
+// pickSize draws a size in [lo, hi] with probability proportional to the number
+// of derivations of that size. If there is none, the smallest feasible size is used.
+func (t *sizeTable) pickSize(rnd *rand.Rand, node *Node, lo, hi int) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hi = min(hi, t.max)
+	weights := make([]*big.Int, 0)
+	for n := max(lo, 0); n <= hi; n++ {
+		weights = append(weights, t.count(node, n))
+	}
+	if idx, ok := t.choose(rnd, weights); ok {
+		return max(lo, 0) + idx, true
+	}
+	for n := 0; n <= t.max; n++ {
+		if t.count(node, n).Sign() != 0 {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// sample chooses uniformly one way to derive n terminals from node and returns
+// the children to expand next, each with the size it has to yield.
+func (t *sizeTable) sample(rnd *rand.Rand, node *Node, n int) []sizedNode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	children := node.GetSymbols()
+	switch node.GetType() {
+	case GrammarTerminal:
+		return nil
+	case GrammarID:
+		prod := t.g.GetNode(node.GetContent())
+		if prod == nil {
+			return nil
+		}
+		return []sizedNode{{node: prod, size: n}}
+	case GrammarOR:
+		weights := make([]*big.Int, len(children))
+		for i, c := range children {
+			weights[i] = t.count(c, n)
+		}
+		idx, ok := t.choose(rnd, weights)
+		if !ok {
+			return nil
+		}
+		return []sizedNode{{node: children[idx], size: n}}
+	case GrammarOptional, GrammarEXT:
+		empty := big.NewInt(0)
+		if n == 0 {
+			empty.SetInt64(1)
+		}
+		idx, ok := t.choose(rnd, []*big.Int{empty, t.seq(node, 0, n)})
+		if !ok || idx == 0 {
+			return nil
+		}
+		return t.split(rnd, node, children, n)
+	case GrammarREP, GrammarPLUS:
+		res := make([]sizedNode, 0)
+		for n > 0 {
+			weights := make([]*big.Int, n)
+			for m := 1; m <= n; m++ {
+				weights[m-1] = new(big.Int).Mul(t.seq(node, 0, m), t.rep(node, n-m))
+			}
+			idx, ok := t.choose(rnd, weights)
+			if !ok {
+				break
+			}
+			res = append(res, t.split(rnd, node, children, idx+1)...)
+			n -= idx + 1
+		}
+		return res
+	case GrammarSUB:
+		if len(children) == 0 {
+			return nil
+		}
+		return []sizedNode{{node: children[0], size: n}}
+	default:
+		return t.split(rnd, node, children, n)
+	}
+}
+
+// split distributes n terminals among the concatenated children.
+func (t *sizeTable) split(rnd *rand.Rand, node *Node, children []*Node, n int) []sizedNode {
+	res := make([]sizedNode, 0, len(children))
+	for i, c := range children {
+		weights := make([]*big.Int, n+1)
+		for m := 0; m <= n; m++ {
+			weights[m] = new(big.Int).Mul(t.count(c, m), t.seq(node, i+1, n-m))
+		}
+		m, ok := t.choose(rnd, weights)
+		if !ok {
+			m = 0
+		}
+		// every expansion needs its own wrapper so that it can carry its own size
+		res = append(res, sizedNode{node: &Node{internal: c.internal}, size: m})
+		n -= m
+	}
+	return res
+}
+
+// choose returns an index with probability proportional to its weight.
+func (t *sizeTable) choose(rnd *rand.Rand, weights []*big.Int) (int, bool) {
+	total := new(big.Int)
+	for _, w := range weights {
+		total.Add(total, w)
+	}
+	if total.Sign() == 0 {
+		return 0, false
+	}
+	r := new(big.Int).Rand(rnd, total)
+	for i, w := range weights {
+		if r.Cmp(w) < 0 {
+			return i, true
+		}
+		r.Sub(r, w)
+	}
+	return len(weights) - 1, true
+}
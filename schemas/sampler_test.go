@@ -0,0 +1,174 @@
+package schemas_test
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// driveHandler expands the top of the symbol stack with the rest of the chain, or with
+// the first of routes whose handler accepts the type of the node. With leftToRight,
+// the first symbol of an expansion is expanded first.
+type driveHandler struct {
+	routes      []schemas.Handler
+	leftToRight bool
+}
+
+func (h *driveHandler) Handle(chain *schemas.Chain, ctx *schemas.Context, cb schemas.ResponseCallBack) {
+	ctx.CurrentNode = ctx.SymbolStack.Top()
+	ctx.ResultBuffer = make([]*schemas.Node, 0)
+	if len(h.routes) == 0 {
+		chain.Next(ctx, cb)
+	}
+	for _, r := range h.routes {
+		if r.Type()&ctx.CurrentNode.GetType() != 0 {
+			r.Handle(&schemas.Chain{}, ctx, cb)
+			break
+		}
+	}
+	if ctx.Error != nil {
+		return
+	}
+	ctx.SymbolStack.Pop()
+	if h.leftToRight {
+		for i := len(ctx.ResultBuffer) - 1; i >= 0; i-- {
+			ctx.SymbolStack.Push(ctx.ResultBuffer[i])
+		}
+	} else {
+		ctx.SymbolStack.Push(ctx.ResultBuffer...)
+	}
+	for i := len(ctx.ResultBuffer) - 1; i >= 0; i-- {
+		ctx.Result.AddNode(ctx.ResultBuffer[i])
+		ctx.Result.AddEdge(ctx.CurrentNode, ctx.ResultBuffer[i])
+	}
+}
+
+func (h *driveHandler) HookRoute() []regexp.Regexp {
+	return make([]regexp.Regexp, 0)
+}
+
+func (h *driveHandler) Name() string {
+	return "drive"
+}
+
+func (h *driveHandler) Type() schemas.GrammarType {
+	return math.MaxInt
+}
+
+// createBinaryTreeGrammar builds S = 'a' | (S, S), whose trees with n leaves are counted by Catalan(n-1).
+func createBinaryTreeGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("S"))
+	s := schemas.NewNode(g, schemas.GrammarProduction, "S", "'a' | (S, S)")
+	or := schemas.NewNode(g, schemas.GrammarOR, "S#0", "'a' | (S, S)")
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "S#2", "S, S")
+	s.AddSymbol(or)
+	or.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "S#1", "'a'"))
+	or.AddSymbol(cat)
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "S#3", "S"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "S#4", "S"))
+	return g
+}
+
+func generate(t *testing.T, g *schemas.Grammar, start string, chain *schemas.Chain) *schemas.Context {
+	ctx, err := schemas.NewContext(g, start, context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for !ctx.GetFinish() {
+		chain.Next(ctx, func(result *schemas.Result) {
+			ctx = result.GetCtx()
+		})
+		ctx.HandlerIndex = 0
+	}
+	return ctx
+}
+
+func countTerminals(ctx *schemas.Context) int {
+	cnt := 0
+	for _, n := range ctx.SymbolStack.GetTrace() {
+		if n.GetType() == schemas.GrammarTerminal {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func TestUniformSizeCount(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	h := &schemas.UniformSizeHandler{MinSize: 1, MaxSize: 10}
+	catalan := []int64{0, 1, 1, 2, 5, 14, 42, 132, 429, 1430, 4862}
+	for n, want := range catalan {
+		if got := h.Count(g, "S", n); got.Int64() != want {
+			t.Errorf("Count(S, %d) = %s, want %d", n, got, want)
+		}
+	}
+}
+
+func TestUniformSizeHandler(t *testing.T) {
+	g := createBinaryTreeGrammar()
+	h := &schemas.UniformSizeHandler{MinSize: 4, MaxSize: 4}
+	chain, err := schemas.CreateChain("uniform", &driveHandler{}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]int)
+	for i := 0; i < 500; i++ {
+		ctx := generate(t, g, "S", chain)
+		if got := countTerminals(ctx); got != 4 {
+			t.Fatalf("generated %d terminals, want 4", got)
+		}
+		shape := ""
+		for _, n := range ctx.SymbolStack.GetTrace() {
+			shape += n.GetID() + " "
+		}
+		seen[shape]++
+	}
+	if len(seen) != 5 {
+		t.Errorf("got %d distinct trees of size 4, want 5", len(seen))
+	}
+	for shape, cnt := range seen {
+		if cnt < 50 {
+			t.Errorf("tree %q sampled %d times out of 500, the distribution is not uniform", shape, cnt)
+		}
+	}
+}
+
+// createSequenceGrammar builds T = 'a', {'b'}, ['c'].
+func createSequenceGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("T"))
+	tt := schemas.NewNode(g, schemas.GrammarProduction, "T", "'a', {'b'}, ['c']")
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "T#0", "'a', {'b'}, ['c']")
+	rep := schemas.NewNode(g, schemas.GrammarREP, "T#2", "{'b'}")
+	opt := schemas.NewNode(g, schemas.GrammarOptional, "T#4", "['c']")
+	tt.AddSymbol(cat)
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "T#1", "'a'"))
+	cat.AddSymbol(rep)
+	cat.AddSymbol(opt)
+	rep.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "T#3", "'b'"))
+	opt.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "T#5", "'c'"))
+	return g
+}
+
+func TestUniformSizeHandlerRepetition(t *testing.T) {
+	g := createSequenceGrammar()
+	h := &schemas.UniformSizeHandler{MinSize: 3, MaxSize: 6}
+	for n, want := range []int64{0, 1, 2, 2, 2, 2, 2} {
+		if got := h.Count(g, "T", n); got.Int64() != want {
+			t.Errorf("Count(T, %d) = %s, want %d", n, got, want)
+		}
+	}
+	chain, err := schemas.CreateChain("uniform", &driveHandler{}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		ctx := generate(t, g, "T", chain)
+		res := ctx.Result.GetResult(nil)
+		if len(res) < 3 || len(res) > 6 || !regexp.MustCompile("^ab*c?$").MatchString(res) {
+			t.Errorf("unexpected result %q", res)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package schemas
+
+import (
+	"strings"
+)
+
+// label names a node of a derivation tree by its grammar node, and the text of a leaf.
+func (n *TreeNode) label() string {
+	id := origin(n.ID)
+	if n.Symbol != nil {
+		id = n.Symbol.GetID()
+	}
+	if n.Leaf {
+		return id + "=" + n.Value
+	}
+	return id
+}
+
+// postorder lists the nodes of a tree in postorder, with the leftmost leaf of every node
+// and the key roots, the nodes which are not the leftmost child of their parent.
+type postorder struct {
+	labels   []string
+	leftmost []int
+	keyroots []int
+}
+
+func newPostorder(t *DerivationTree) postorder {
+	p := postorder{}
+	var visit func(n *TreeNode) int
+	visit = func(n *TreeNode) int {
+		first := -1
+		for _, c := range n.Children {
+			if i := visit(c); first < 0 {
+				first = p.leftmost[i]
+			}
+		}
+		p.labels = append(p.labels, n.label())
+		if first < 0 {
+			first = len(p.labels) - 1
+		}
+		p.leftmost = append(p.leftmost, first)
+		return len(p.labels) - 1
+	}
+	if t.Root != nil {
+		visit(t.Root)
+	}
+	seen := make(map[int]bool)
+	for i := len(p.labels) - 1; i >= 0; i-- {
+		if !seen[p.leftmost[i]] {
+			seen[p.leftmost[i]] = true
+			p.keyroots = append(p.keyroots, i)
+		}
+	}
+	// the key roots are visited bottom up
+	for i, j := 0, len(p.keyroots)-1; i < j; i, j = i+1, j-1 {
+		p.keyroots[i], p.keyroots[j] = p.keyroots[j], p.keyroots[i]
+	}
+	return p
+}
+
+// TreeEditDistance returns the smallest number of nodes to insert, delete or relabel to turn
+// the tree a into b, by the algorithm of Zhang and Shasha. The nodes are labelled by their
+// grammar nodes, and the leaves by their text as well.
+func TreeEditDistance(a, b *DerivationTree) int {
+	pa, pb := newPostorder(a), newPostorder(b)
+	n, m := len(pa.labels), len(pb.labels)
+	if n == 0 || m == 0 {
+		return n + m
+	}
+	dist := make([][]int, n)
+	for i := range dist {
+		dist[i] = make([]int, m)
+	}
+	for _, i := range pa.keyroots {
+		for _, j := range pb.keyroots {
+			i0, j0 := pa.leftmost[i], pb.leftmost[j]
+			// forest[x][y] is the distance between the forests of the nodes i0 to i0+x-1 and j0 to j0+y-1
+			forest := make([][]int, i-i0+2)
+			for x := range forest {
+				forest[x] = make([]int, j-j0+2)
+				forest[x][0] = x
+			}
+			for y := range forest[0] {
+				forest[0][y] = y
+			}
+			for x := i0; x <= i; x++ {
+				for y := j0; y <= j; y++ {
+					fx, fy := x-i0+1, y-j0+1
+					best := min(forest[fx-1][fy], forest[fx][fy-1]) + 1
+					if pa.leftmost[x] == i0 && pb.leftmost[y] == j0 {
+						relabel := 0
+						if pa.labels[x] != pb.labels[y] {
+							relabel = 1
+						}
+						forest[fx][fy] = min(best, forest[fx-1][fy-1]+relabel)
+						dist[x][y] = forest[fx][fy]
+					} else {
+						forest[fx][fy] = min(best, forest[pa.leftmost[x]-i0][pb.leftmost[y]-j0]+dist[x][y])
+					}
+				}
+			}
+		}
+	}
+	return dist[n-1][m-1]
+}
+
+// bag counts the labels of the nodes of the tree.
+func (t *DerivationTree) bag() map[string]int {
+	res := make(map[string]int)
+	t.Walk(func(n *TreeNode) bool {
+		res[n.label()]++
+		return true
+	})
+	return res
+}
+
+// BagSimilarity compares the nodes of the trees, whatever their place: it is the number of
+// nodes they have in common over the number of nodes of both, from 0 to 1 for the same nodes.
+func BagSimilarity(a, b *DerivationTree) float64 {
+	ba, bb := a.bag(), b.bag()
+	common, all := 0, 0
+	for label, ca := range ba {
+		common += min(ca, bb[label])
+		all += max(ca, bb[label])
+	}
+	for label, cb := range bb {
+		if _, ok := ba[label]; !ok {
+			all += cb
+		}
+	}
+	if all == 0 {
+		return 1
+	}
+	return float64(common) / float64(all)
+}
+
+// kPaths lists the paths of k nodes of the tree, from a node down to one of its descendants.
+func (t *DerivationTree) kPaths(k int) map[string]bool {
+	res := make(map[string]bool)
+	path := make([]string, 0)
+	var visit func(n *TreeNode)
+	visit = func(n *TreeNode) {
+		path = append(path, n.label())
+		if len(path) >= k {
+			res[strings.Join(path[len(path)-k:], "\x00")] = true
+		}
+		for _, c := range n.Children {
+			visit(c)
+		}
+		path = path[:len(path)-1]
+	}
+	if t.Root != nil && k > 0 {
+		visit(t.Root)
+	}
+	return res
+}
+
+// KPathSimilarity compares the paths of k nodes of the trees, like the k-path coverage of
+// Havrikov and Zeller: it is the Jaccard index of their sets of paths, 1 for the same paths.
+func KPathSimilarity(a, b *DerivationTree, k int) float64 {
+	pa, pb := a.kPaths(k), b.kPaths(k)
+	common := 0
+	for p := range pa {
+		if pb[p] {
+			common++
+		}
+	}
+	all := len(pa) + len(pb) - common
+	if all == 0 {
+		return 1
+	}
+	return float64(common) / float64(all)
+}
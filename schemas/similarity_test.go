@@ -0,0 +1,48 @@
+package schemas_test
+
+import (
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+func TestSimilarity(t *testing.T) {
+	g := createPairGrammar()
+	tree := func(input string) *schemas.DerivationTree {
+		d, err := g.Parse("E", input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.Tree()
+	}
+	x, pair, nested := tree("x"), tree("<xx>"), tree("<x<xx>>")
+	for _, c := range []struct {
+		a, b *schemas.DerivationTree
+		want int
+	}{
+		{pair, pair, 0},
+		{x, pair, 12},
+		// the second x of the pair becomes a pair
+		{pair, nested, 12},
+	} {
+		if d := schemas.TreeEditDistance(c.a, c.b); d != c.want || schemas.TreeEditDistance(c.b, c.a) != d {
+			t.Errorf("the distance from %q to %q is %d, want %d", c.a.Text, c.b.Text, d, c.want)
+		}
+	}
+	if d := schemas.TreeEditDistance(&schemas.DerivationTree{}, x); d != 5 {
+		t.Errorf("the distance from the empty tree is %d", d)
+	}
+
+	if s := schemas.BagSimilarity(pair, pair); s != 1 {
+		t.Errorf("got %f", s)
+	}
+	if s, far := schemas.BagSimilarity(pair, nested), schemas.BagSimilarity(x, nested); s <= far || s >= 1 {
+		t.Errorf("got %f and %f", s, far)
+	}
+	if s := schemas.KPathSimilarity(nested, nested, 3); s != 1 {
+		t.Errorf("got %f", s)
+	}
+	if s, far := schemas.KPathSimilarity(pair, nested, 5), schemas.KPathSimilarity(x, nested, 5); s <= far || s >= 1 {
+		t.Errorf("got %f and %f", s, far)
+	}
+}
@@ -0,0 +1,416 @@
+package schemas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidDerivation = errors.New("the derivation does not follow the grammar")
+
+// DerivationTree is the derivation of an input as a tree, see Derivation.Tree.
+type DerivationTree struct {
+	Root   *TreeNode `json:"root"`
+	Text   string    `json:"text"`   // text of the input, indexed by the spans of the nodes
+	Tokens []string  `json:"tokens"` // see Derivation.Tokens
+}
+
+// TreeNode is a node of a DerivationTree, its children are in the order of the text.
+type TreeNode struct {
+	ID       string // ID of the derived node, e.g. expr#2
+	Symbol   *Node  // node of the grammar it is derived from, nil if it is unknown
+	Type     GrammarType
+	Content  string
+	Lexical  bool   // the node is a lexical production
+	Leaf     bool   // the text of the node is Value, a terminal or chosen by a ProviderHandler
+	Value    string // text of a leaf
+	Parent   *TreeNode
+	Children []*TreeNode
+
+	Start, End            int // span of its text in DerivationTree.Text
+	FirstToken, LastToken int // its tokens are DerivationTree.Tokens[FirstToken:LastToken]
+
+	tree *DerivationTree
+}
+
+// origin returns the ID of the grammar node a derived node is named after: id#count.
+func origin(id string) string {
+	if i := strings.LastIndex(id, "#"); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// Tree converts the derivation into a DerivationTree. It can be taken at any point
+// of the generation, the symbols not expanded yet are leaves without text. The tree is
+// built from the derivation graph when asked for, not on the frames of the symbol stack
+// during the generation: the graph records every expansion already, and a generation
+// whose tree is never taken does not pay for it.
+func (d *Derivation) Tree() *DerivationTree {
+	t := &DerivationTree{Tokens: make([]string, 0)}
+	text := &strings.Builder{}
+	w := &layoutWriter{layout: &Layout{}, out: text, onToken: func(token string) {
+		t.Tokens = append(t.Tokens, token)
+	}}
+	var cur *TreeNode
+	d.walkTokens(w, nil, func(n *Node, leave bool) {
+		offset := text.Len() + w.word.Len()
+		if !leave {
+			node := &TreeNode{
+				ID:         n.GetID(),
+				Type:       n.GetType(),
+				Content:    n.GetContent(),
+				Lexical:    n.IsLexical(),
+				Parent:     cur,
+				Start:      offset,
+				FirstToken: len(t.Tokens),
+				tree:       t,
+			}
+			_, valued := d.values[n.GetID()]
+			node.Leaf = valued || n.GetType() == GrammarTerminal
+			if d.origin != nil {
+				node.Symbol = d.origin.GetNode(origin(n.GetID()))
+			}
+			if cur == nil {
+				t.Root = node
+			} else {
+				cur.Children = append(cur.Children, node)
+			}
+			cur = node
+			return
+		}
+		cur.End = offset
+		cur.LastToken = len(t.Tokens)
+		if w.lexical > 0 && cur.End > cur.Start {
+			// within a lexical production, the token is written when it is complete
+			cur.LastToken++
+		}
+		cur = cur.Parent
+	})
+	t.Text = text.String()
+	t.Walk(func(n *TreeNode) bool {
+		if n.Leaf {
+			n.Value = t.Text[n.Start:n.End]
+		}
+		return true
+	})
+	return t
+}
+
+// Walk visits the nodes in preorder, the children of a node are skipped if fn returns false.
+func (t *DerivationTree) Walk(fn func(n *TreeNode) bool) {
+	var walk func(n *TreeNode)
+	walk = func(n *TreeNode) {
+		if !fn(n) {
+			return
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	if t.Root != nil {
+		walk(t.Root)
+	}
+}
+
+// Text returns the text derived from the node.
+func (n *TreeNode) Text() string {
+	return n.tree.Text[n.Start:n.End]
+}
+
+// Production returns the name of the production of a production node, or "".
+func (n *TreeNode) Production() string {
+	if n.Type != GrammarProduction {
+		return ""
+	}
+	return trimNumber(n.ID)
+}
+
+func (n *TreeNode) MarshalJSON() ([]byte, error) {
+	symbol := ""
+	if n.Symbol != nil {
+		symbol = n.Symbol.GetID()
+	}
+	return json.Marshal(struct {
+		ID       string      `json:"id"`
+		Symbol   string      `json:"symbol,omitempty"`
+		Type     string      `json:"type"`
+		Content  string      `json:"content"`
+		Lexical  bool        `json:"lexical,omitempty"`
+		Value    *string     `json:"value,omitempty"`
+		Span     [2]int      `json:"span"`
+		Tokens   [2]int      `json:"tokens"`
+		Children []*TreeNode `json:"children,omitempty"`
+	}{
+		ID:       n.ID,
+		Symbol:   symbol,
+		Type:     GetGrammarTypeStr(n.Type),
+		Content:  n.Content,
+		Lexical:  n.Lexical,
+		Value:    n.value(),
+		Span:     [2]int{n.Start, n.End},
+		Tokens:   [2]int{n.FirstToken, n.LastToken},
+		Children: n.Children,
+	})
+}
+
+func (n *TreeNode) value() *string {
+	if !n.Leaf {
+		return nil
+	}
+	return &n.Value
+}
+
+type jsonTreeNode struct {
+	Symbol   string          `json:"symbol"`
+	Value    *string         `json:"value"`
+	Children []*jsonTreeNode `json:"children"`
+}
+
+// UnmarshalTree reads a DerivationTree written in JSON back into a derivation of the grammar
+// g: the nodes are linked to the grammar by their symbols and derived again, see DerivationTree.Derivation.
+func UnmarshalTree(g *Grammar, data []byte) (*Derivation, error) {
+	var res struct {
+		Root *jsonTreeNode `json:"root"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	if res.Root == nil {
+		return nil, fmt.Errorf("%w: the tree has no root", ErrInvalidDerivation)
+	}
+	var build func(j *jsonTreeNode, parent *TreeNode) (*TreeNode, error)
+	build = func(j *jsonTreeNode, parent *TreeNode) (*TreeNode, error) {
+		symbol := g.GetNode(j.Symbol)
+		if symbol == nil {
+			return nil, fmt.Errorf("%w: the symbol %q does not exist", ErrInvalidDerivation, j.Symbol)
+		}
+		n := &TreeNode{Symbol: symbol, Type: symbol.GetType(), Content: symbol.GetContent(), Parent: parent}
+		if j.Value != nil {
+			n.Leaf, n.Value = true, *j.Value
+		}
+		for _, c := range j.Children {
+			child, err := build(c, n)
+			if err != nil {
+				return nil, err
+			}
+			n.Children = append(n.Children, child)
+		}
+		return n, nil
+	}
+	root, err := build(res.Root, nil)
+	if err != nil {
+		return nil, err
+	}
+	t := &DerivationTree{Root: root}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t.Derivation()
+}
+
+// SExpr writes the tree as an S-expression of its productions and leaves, e.g. (expr (term "a") "+" (expr (term "b"))).
+func (t *DerivationTree) SExpr() string {
+	b := &strings.Builder{}
+	var write func(n *TreeNode)
+	write = func(n *TreeNode) {
+		switch {
+		case n.Leaf && n.Type == GrammarProduction:
+			fmt.Fprintf(b, " (%s %s)", n.Production(), strconv.Quote(n.Value))
+		case n.Leaf:
+			fmt.Fprintf(b, " %s", strconv.Quote(n.Value))
+		case n.Type == GrammarProduction:
+			fmt.Fprintf(b, " (%s", n.Production())
+			for _, c := range n.Children {
+				write(c)
+			}
+			b.WriteString(")")
+		default:
+			for _, c := range n.Children {
+				write(c)
+			}
+		}
+	}
+	if t.Root != nil {
+		write(t.Root)
+	}
+	return strings.TrimPrefix(b.String(), " ")
+}
+
+// WriteDOT writes the tree in the DOT language of Graphviz.
+func (t *DerivationTree) WriteDOT(w io.Writer) error {
+	b := &strings.Builder{}
+	b.WriteString("digraph derivation {\n\tnode [shape=box];\n")
+	ids := make(map[*TreeNode]int)
+	t.Walk(func(n *TreeNode) bool {
+		ids[n] = len(ids)
+		label := n.ID + "\n" + GetGrammarTypeStr(n.Type)
+		if n.Leaf {
+			label = n.ID + "\n" + strconv.Quote(n.Value)
+		}
+		fmt.Fprintf(b, "\tn%d [label=%s];\n", ids[n], strconv.Quote(label))
+		if n.Parent != nil {
+			fmt.Fprintf(b, "\tn%d -> n%d;\n", ids[n.Parent], ids[n])
+		}
+		return true
+	})
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Visualize prints the derivation tree in the DOT language, see DerivationTree.WriteDOT.
+func (d *Derivation) Visualize() {
+	_ = d.Tree().WriteDOT(os.Stdout)
+}
+
+// SaveTree writes the derivation tree to filename in the DOT language, see DerivationTree.WriteDOT.
+func (d *Derivation) SaveTree(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return d.Tree().WriteDOT(f)
+}
+
+// Clone deep-copies the subtree of n, the copy has no parent.
+func (n *TreeNode) Clone() *TreeNode {
+	res := *n
+	res.Parent = nil
+	res.Children = make([]*TreeNode, len(n.Children))
+	for i, c := range n.Children {
+		res.Children[i] = c.Clone()
+		res.Children[i].Parent = &res
+	}
+	return &res
+}
+
+// Validate checks that every node of the tree is expanded as its grammar node says,
+// the leaves apart, whose text may have been chosen by a ProviderHandler.
+func (t *DerivationTree) Validate() error {
+	if t.Root == nil {
+		return fmt.Errorf("%w: the tree is empty", ErrInvalidDerivation)
+	}
+	var err error
+	t.Walk(func(n *TreeNode) bool {
+		if err == nil {
+			err = n.validate()
+		}
+		return err == nil && !n.Leaf
+	})
+	return err
+}
+
+func (n *TreeNode) validate() error {
+	invalid := func(format string, a ...any) error {
+		return fmt.Errorf("%w: %s %s", ErrInvalidDerivation, n.ID, fmt.Sprintf(format, a...))
+	}
+	if n.Symbol == nil {
+		return invalid("is not linked to the grammar")
+	}
+	if n.Leaf {
+		return nil
+	}
+	for _, c := range n.Children {
+		if c.Symbol == nil {
+			return invalid("has a child not linked to the grammar")
+		}
+	}
+	symbols := sourceOrder(n.Symbol.GetSymbols())
+	is := func(c *TreeNode, s *Node) bool {
+		return c.Symbol.GetID() == s.GetID()
+	}
+	switch n.Type {
+	case GrammarID:
+		if len(n.Children) != 1 || n.Children[0].Symbol.GetID() != n.Content {
+			return invalid("does not derive %s", n.Content)
+		}
+	case GrammarOR:
+		if len(n.Children) != 1 || !slices.ContainsFunc(symbols, func(s *Node) bool { return is(n.Children[0], s) }) {
+			return invalid("does not derive one of its alternatives")
+		}
+	case GrammarSUB:
+		if len(symbols) > 0 && (len(n.Children) != 1 || !is(n.Children[0], symbols[0])) {
+			return invalid("does not derive its first child")
+		}
+	case GrammarREP, GrammarPLUS, GrammarOptional, GrammarEXT:
+		if len(symbols) == 0 {
+			break
+		}
+		cnt := len(n.Children) / len(symbols)
+		switch {
+		case len(n.Children)%len(symbols) != 0:
+			return invalid("has a repetition cut short")
+		case n.Type == GrammarPLUS && cnt < 1:
+			return invalid("is repeated no time")
+		case (n.Type == GrammarOptional || n.Type == GrammarEXT) && cnt > 1:
+			return invalid("is repeated %d times", cnt)
+		}
+		for i, c := range n.Children {
+			if !is(c, symbols[i%len(symbols)]) {
+				return invalid("derives %s out of order", c.ID)
+			}
+		}
+	default:
+		if n.Type == GrammarTerminal {
+			return invalid("is a terminal with children")
+		}
+		if len(n.Children) != len(symbols) {
+			return invalid("has %d children, not %d", len(n.Children), len(symbols))
+		}
+		for i, c := range n.Children {
+			if !is(c, symbols[i]) {
+				return invalid("derives %s out of order", c.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// Derivation builds the derivation of the tree, e.g. once its nodes are edited. The nodes are
+// derived again, so their IDs may change, and the text of every leaf is kept as its value.
+func (t *DerivationTree) Derivation() (*Derivation, error) {
+	if t.Root == nil || t.Root.Symbol == nil {
+		return nil, fmt.Errorf("%w: the root is not linked to the grammar", ErrInvalidDerivation)
+	}
+	d := &Derivation{
+		Grammar:     newDerivationGrammar(t.Root.Symbol.GetID()),
+		origin:      t.Root.Symbol.GetGrammar(),
+		EdgeHistory: make([]string, 0),
+		SymbolCnt:   make(map[string]int),
+	}
+	// derive expands the node of the stack w as the tree node n says, like a driver would
+	var derive func(n *TreeNode, w *Node) error
+	derive = func(n *TreeNode, w *Node) error {
+		if n.Leaf {
+			d.setValue(w, n.Value)
+		}
+		children := make([]*Node, len(n.Children))
+		for i, c := range n.Children {
+			if c.Symbol == nil {
+				return fmt.Errorf("%w: %s is not linked to the grammar", ErrInvalidDerivation, c.ID)
+			}
+			children[i] = &Node{internal: c.Symbol.internal}
+		}
+		for i := len(children) - 1; i >= 0; i-- {
+			d.AddNode(children[i])
+			d.AddEdge(w, children[i])
+		}
+		for i, c := range n.Children {
+			if err := derive(c, children[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := derive(t.Root, &Node{internal: t.Root.Symbol.internal}); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,178 @@
+package schemas_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/CUHK-SE-Group/generic-generator/schemas"
+)
+
+// createPairGrammar E = T | ('<', E, E, '>') | T; T = 'x', whose derivations are finite
+func createPairGrammar() *schemas.Grammar {
+	g := schemas.NewGrammar(schemas.WithStartSym("E"))
+	e := schemas.NewNode(g, schemas.GrammarProduction, "E", "T | ('<', E, E, '>') | T")
+	or := schemas.NewNode(g, schemas.GrammarOR, "E#0", "T | ('<', E, E, '>') | T")
+	e.AddSymbol(or)
+	or.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "E#1", "T"))
+	cat := schemas.NewNode(g, schemas.GrammarCatenate, "E#2", "'<', E, E, '>'")
+	or.AddSymbol(cat)
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "E#3", "'<'"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "E#4", "E"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "E#5", "E"))
+	cat.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "E#6", "'>'"))
+	or.AddSymbol(schemas.NewNode(g, schemas.GrammarID, "E#7", "T"))
+	tp := schemas.NewNode(g, schemas.GrammarProduction, "T", "'x'")
+	tp.AddSymbol(schemas.NewNode(g, schemas.GrammarTerminal, "T#0", "'x'"))
+	return g
+}
+
+// pairs consumes a text of createPairGrammar from s.
+func pairs(s string) (string, bool) {
+	if strings.HasPrefix(s, "x") {
+		return s[1:], true
+	}
+	if !strings.HasPrefix(s, "<") {
+		return s, false
+	}
+	s, ok := pairs(s[1:])
+	if ok {
+		s, ok = pairs(s)
+	}
+	if !ok || !strings.HasPrefix(s, ">") {
+		return s, false
+	}
+	return s[1:], true
+}
+
+func TestDerivationTree(t *testing.T) {
+	chain, err := schemas.CreateChain("tree", &driveHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.OrHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		ctx := newContext(t, createPairGrammar())
+		if _, err := schemas.Generate(ctx, chain); err != nil {
+			t.Fatal(err)
+		}
+		tree := ctx.Result.Tree()
+		if rest, ok := pairs(tree.Text); !ok || rest != "" || tree.Text != ctx.Result.GetResult(nil) {
+			t.Fatalf("got %q", tree.Text)
+		}
+		tree.Walk(func(n *schemas.TreeNode) bool {
+			if n.Symbol == nil || n.Symbol.GetType() != n.Type {
+				t.Fatalf("%s is not linked to its grammar node", n.ID)
+			}
+			if n.Type == schemas.GrammarCatenate && len(n.Children) != len(n.Symbol.GetSymbols()) {
+				t.Fatalf("%s has %d children", n.ID, len(n.Children))
+			}
+			start := n.Start
+			for _, c := range n.Children {
+				if c.Parent != n || c.Start != start {
+					t.Fatalf("the span of %s does not follow its siblings", c.ID)
+				}
+				start = c.End
+			}
+			if len(n.Children) != 0 && start != n.End {
+				t.Fatalf("the children of %s do not span it", n.ID)
+			}
+			return true
+		})
+	}
+}
+
+func TestDerivationTreeExport(t *testing.T) {
+	g := createNumberStatementGrammar()
+	if err := g.SetLexical(true, "N"); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := schemas.CreateChain("tree", &driveHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(t, g)
+	if _, err := schemas.Generate(ctx, chain); err != nil {
+		t.Fatal(err)
+	}
+	tree := ctx.Result.Tree()
+	if s := tree.SExpr(); s != `(S (K "let") (N (D "1") (D "1")))` {
+		t.Errorf("got %s", s)
+	}
+	var d *schemas.TreeNode
+	tree.Walk(func(n *schemas.TreeNode) bool {
+		if n.Production() == "D" {
+			d = n
+		}
+		return true
+	})
+	// the digits are part of the token 11
+	if d == nil || d.Text() != "1" || d.Start != 4 || d.FirstToken != 1 || d.LastToken != 2 {
+		t.Errorf("got %+v", d)
+	}
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Tokens []string
+		Root   struct {
+			ID     string
+			Symbol string
+			Type   string
+			Span   [2]int
+		}
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Root.ID != "S#0" || res.Root.Symbol != "S" || res.Root.Type != schemas.GetGrammarTypeStr(schemas.GrammarProduction) || res.Root.Span != [2]int{0, 5} || len(res.Tokens) != 2 {
+		t.Errorf("got %s", data)
+	}
+
+	back, err := schemas.UnmarshalTree(g, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := back.Tree().SExpr(); s != tree.SExpr() || back.GetResult(nil) != ctx.Result.GetResult(nil) {
+		t.Errorf("read back %s", s)
+	}
+
+	dot := &strings.Builder{}
+	if err := tree.WriteDOT(dot); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(dot.String(), "digraph derivation {") || !strings.Contains(dot.String(), "n0 -> n1;") {
+		t.Errorf("got %s", dot.String())
+	}
+}
+
+func TestDerivationTreeRebuild(t *testing.T) {
+	chain, err := schemas.CreateChain("tree", &driveHandler{}, &schemas.CatHandler{}, &schemas.IDHandler{}, &schemas.OrHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		ctx := newContext(t, createPairGrammar())
+		if _, err := schemas.Generate(ctx, chain); err != nil {
+			t.Fatal(err)
+		}
+		tree := ctx.Result.Tree()
+		if err := tree.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		d, err := (&schemas.DerivationTree{Root: tree.Root.Clone()}).Derivation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Tree(); got.Text != tree.Text || got.SExpr() != tree.SExpr() || got.Validate() != nil {
+			t.Fatalf("rebuilt %s from %s", got.SExpr(), tree.SExpr())
+		}
+	}
+
+	ctx := newContext(t, createPairGrammar())
+	if err := ctx.Result.Tree().Validate(); !errors.Is(err, schemas.ErrInvalidDerivation) {
+		t.Fatalf("an empty derivation is valid: %v", err)
+	}
+}